@@ -0,0 +1,53 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"student_api/internal/students"
+)
+
+// authMiddleware requires an "Authorization: Bearer <key>" header matching
+// one of apiKeys. It is a no-op when no keys are configured so local
+// development works without credentials. publicPaths are never checked, so
+// probes can reach the health endpoints.
+func authMiddleware(apiKeys []string, publicPaths ...string) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        if len(apiKeys) == 0 {
+            return next
+        }
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            for _, path := range publicPaths {
+                if r.URL.Path == path {
+                    next.ServeHTTP(w, r)
+                    return
+                }
+            }
+
+            token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+            if !ok || token == "" {
+                w.Header().Set("WWW-Authenticate", "Bearer")
+                students.WriteJSONError(w, http.StatusUnauthorized, "Missing API key")
+                return
+            }
+            if !validAPIKey(apiKeys, token) {
+                w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+                students.WriteJSONError(w, http.StatusUnauthorized, "Invalid API key")
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// validAPIKey compares token against every key in constant time
+func validAPIKey(apiKeys []string, token string) bool {
+    valid := false
+    for _, key := range apiKeys {
+        if subtle.ConstantTimeCompare([]byte(key), []byte(token)) == 1 {
+            valid = true
+        }
+    }
+    return valid
+}
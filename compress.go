@@ -0,0 +1,163 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// gzipMinSize is the smallest body worth compressing; shorter responses are
+// sent as they are since gzip's overhead would outweigh the savings
+const gzipMinSize = 1024
+
+var gzipWriters = sync.Pool{
+    New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// gzipMiddleware compresses responses for clients sending
+// "Accept-Encoding: gzip". The first gzipMinSize bytes are buffered to decide
+// whether compression is worthwhile, so it must sit inside loggingMiddleware
+// for the logged status and size to reflect what was actually sent.
+func gzipMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        w.Header().Add("Vary", "Accept-Encoding")
+        if !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+            next.ServeHTTP(w, r)
+            return
+        }
+        gw := &gzipResponseWriter{ResponseWriter: w}
+        defer gw.finish()
+        next.ServeHTTP(gw, r)
+    })
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+    for _, part := range strings.Split(header, ",") {
+        coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+        if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+            continue
+        }
+        q, ok := strings.CutPrefix(strings.TrimSpace(params), "q=")
+        if !ok {
+            return true
+        }
+        weight, err := strconv.ParseFloat(q, 64)
+        return err == nil && weight > 0
+    }
+    return false
+}
+
+// gzipResponseWriter buffers the start of a response until it knows whether
+// to compress it, then either streams through a gzip.Writer or passes every
+// write straight to the wrapped writer
+type gzipResponseWriter struct {
+    http.ResponseWriter
+    status  int
+    buf     []byte
+    started bool
+    gz      *gzip.Writer
+}
+
+func (gw *gzipResponseWriter) WriteHeader(code int) {
+    if gw.started || gw.status != 0 {
+        return
+    }
+    // Informational and bodiless responses have nothing to compress
+    if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified {
+        gw.status = code
+        gw.start(false)
+        return
+    }
+    gw.status = code
+}
+
+func (gw *gzipResponseWriter) Write(b []byte) (int, error) {
+    if gw.started {
+        if gw.gz != nil {
+            return gw.gz.Write(b)
+        }
+        return gw.ResponseWriter.Write(b)
+    }
+    gw.buf = append(gw.buf, b...)
+    if len(gw.buf) >= gzipMinSize {
+        if err := gw.start(true); err != nil {
+            return 0, err
+        }
+    }
+    return len(b), nil
+}
+
+// start sends the headers, compressing the body if compress is set and the
+// handler hasn't already encoded it, then writes out the buffered bytes
+func (gw *gzipResponseWriter) start(compress bool) error {
+    gw.started = true
+    if gw.status == 0 {
+        gw.status = http.StatusOK
+    }
+    h := gw.Header()
+    if compress && h.Get("Content-Encoding") == "" {
+        if h.Get("Content-Type") == "" {
+            // Sniff before compressing, as net/http would sniff the gzip bytes
+            h.Set("Content-Type", http.DetectContentType(gw.buf))
+        }
+        h.Set("Content-Encoding", "gzip")
+        h.Del("Content-Length")
+        gw.gz = gzipWriters.Get().(*gzip.Writer)
+        gw.gz.Reset(gw.ResponseWriter)
+    }
+    gw.ResponseWriter.WriteHeader(gw.status)
+
+    buf := gw.buf
+    gw.buf = nil
+    if len(buf) == 0 {
+        return nil
+    }
+    var err error
+    if gw.gz != nil {
+        _, err = gw.gz.Write(buf)
+    } else {
+        _, err = gw.ResponseWriter.Write(buf)
+    }
+    return err
+}
+
+// Flush sends what has been written so far. A response flushed before it
+// reaches gzipMinSize, such as an event stream, is left uncompressed.
+func (gw *gzipResponseWriter) Flush() {
+    if !gw.started {
+        gw.start(len(gw.buf) >= gzipMinSize)
+    }
+    if gw.gz != nil {
+        gw.gz.Flush()
+    }
+    if f, ok := gw.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (gw *gzipResponseWriter) Unwrap() http.ResponseWriter {
+    return gw.ResponseWriter
+}
+
+// finish writes out a response that stayed below gzipMinSize, or closes the
+// gzip stream of one that didn't
+func (gw *gzipResponseWriter) finish() {
+    if !gw.started {
+        if gw.status == 0 && len(gw.buf) == 0 {
+            return // Nothing was written; let net/http send its default 200
+        }
+        gw.start(false)
+        return
+    }
+    if gw.gz != nil {
+        gw.gz.Close()
+        gw.gz.Reset(io.Discard)
+        gzipWriters.Put(gw.gz)
+        gw.gz = nil
+    }
+}
@@ -0,0 +1,101 @@
+package students
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// CircuitOpenError is returned without calling Ollama while the circuit
+// breaker is open, i.e. after repeated failures
+type CircuitOpenError struct {
+    RetryAfter time.Duration // Until the breaker lets a probe through
+}
+
+func (e *CircuitOpenError) Error() string {
+    return fmt.Sprintf("Ollama API circuit open, retry in %v", e.RetryAfter.Round(time.Second))
+}
+
+type breakerState int
+
+const (
+    breakerClosed   breakerState = iota // Calls go through
+    breakerOpen                         // Calls fail fast until the cooldown passes
+    breakerHalfOpen                     // One probe call decides between the two
+)
+
+// circuitBreaker stops calls to Ollama after threshold consecutive failures.
+// Once cooldown has passed a single probe is let through: if it succeeds
+// the breaker closes again, otherwise it stays open for another cooldown.
+// A zero threshold disables it.
+type circuitBreaker struct {
+    threshold int
+    cooldown  time.Duration
+
+    mu       sync.Mutex
+    state    breakerState
+    failures int       // Consecutive failures while closed
+    openedAt time.Time // When the breaker last opened
+}
+
+// allow reports whether a call may go ahead, returning a *CircuitOpenError
+// if not. A caller that is allowed must report the outcome to record.
+func (b *circuitBreaker) allow() error {
+    if b.threshold <= 0 {
+        return nil
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    switch b.state {
+    case breakerOpen:
+        if wait := b.cooldown - time.Since(b.openedAt); wait > 0 {
+            return &CircuitOpenError{RetryAfter: wait}
+        }
+        b.state = breakerHalfOpen
+        return nil
+    case breakerHalfOpen:
+        // A probe is already in flight; its outcome will be known shortly
+        return &CircuitOpenError{RetryAfter: time.Second}
+    }
+    return nil
+}
+
+// record reports the outcome of a call that allow let through. Errors that
+// aren't Ollama's fault, such as the caller going away or running out of
+// time, count for nothing; generate returns those as the bare ctx.Err().
+func (b *circuitBreaker) record(err error) {
+    if b.threshold <= 0 {
+        return
+    }
+    b.mu.Lock()
+    defer b.mu.Unlock()
+
+    switch {
+    case err == nil:
+        b.state = breakerClosed
+        b.failures = 0
+    case ollamaFault(err):
+        b.failures++
+        if b.state == breakerHalfOpen || b.failures >= b.threshold {
+            b.state = breakerOpen
+            b.openedAt = time.Now()
+            b.failures = 0
+        }
+    case b.state == breakerHalfOpen:
+        // The probe proved nothing; let the next call try again
+        b.state = breakerOpen
+        b.openedAt = time.Time{}
+    }
+}
+
+// ollamaFault reports whether err says something about Ollama's health. The
+// caller's own context ending doesn't.
+func ollamaFault(err error) bool {
+    if err == context.Canceled || err == context.DeadlineExceeded {
+        return false
+    }
+    return errors.Is(err, ErrOllamaUnavailable) || errors.Is(err, ErrOllamaBadResponse) || errors.Is(err, ErrOllamaUnexpectedContent) || isTimeout(err)
+}
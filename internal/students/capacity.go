@@ -0,0 +1,62 @@
+package students
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SetCapacity caps the number of stored students at max, zero meaning no
+// cap. Soft-deleted students take up room only if countDeleted is set; if
+// not, restoring one needs room too. It must be called before the server
+// starts handling requests.
+func (s *Server) SetCapacity(max int, countDeleted bool) {
+    s.maxStudents = max
+    s.countDeleted = countDeleted
+}
+
+// reserveCapacity checks that n more students fit under the cap. While the
+// caller stores them it holds the capacity lock, so concurrent creates can't
+// both take the last of the room; release must be called once they are
+// stored. If there is no room it answers 507 and ok is false.
+func (s *Server) reserveCapacity(w http.ResponseWriter, r *http.Request, n int) (release func(), ok bool) {
+    if s.maxStudents <= 0 {
+        return func() {}, true
+    }
+    s.capacityMu.Lock()
+
+    used, err := s.usedCapacity(r.Context())
+    if err != nil {
+        s.capacityMu.Unlock()
+        writeStoreError(w, err)
+        return nil, false
+    }
+    if room := s.maxStudents - used; n > room {
+        s.capacityMu.Unlock()
+        msg := fmt.Sprintf("Student capacity of %d reached", s.maxStudents)
+        if n > 1 && room > 0 {
+            msg = fmt.Sprintf("Storing %d students would exceed the capacity of %d; there is room for %d more", n, s.maxStudents, room)
+        }
+        WriteJSONError(w, http.StatusInsufficientStorage, msg)
+        return nil, false
+    }
+    return s.capacityMu.Unlock, true
+}
+
+// usedCapacity counts the students taking up room under the cap
+func (s *Server) usedCapacity(ctx context.Context) (int, error) {
+    if s.countDeleted {
+        return s.store.Count(ctx)
+    }
+    studentList, err := s.store.GetAll(ctx)
+    if err != nil {
+        return 0, err
+    }
+    used := 0
+    for _, student := range studentList {
+        if student.DeletedAt == nil {
+            used++
+        }
+    }
+    return used, nil
+}
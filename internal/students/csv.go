@@ -0,0 +1,208 @@
+package students
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvColumns is the column layout used for CSV import
+var csvColumns = []string{"name", "age", "email"}
+
+// csvExportColumns is the header row written by CSV export
+var csvExportColumns = []string{"id", "name", "age", "email", "active", "created_at", "updated_at", "deleted_at"}
+
+// importFailure reports a CSV row that could not be imported
+type importFailure struct {
+    Line  int    `json:"line"`
+    Error string `json:"error"`
+}
+
+// csvRow is a parsed CSV record waiting to be stored
+type csvRow struct {
+    line    int
+    student Student
+}
+
+// ImportStudents handles POST /students/import, creating a student for every
+// row of a text/csv body with the columns name,age,email. A header row is
+// skipped if present, and an empty age takes the server's default age if it
+// has one. Valid rows are stored even if others fail; the response
+// counts the imported rows and lists the failed ones by line number.
+func (s *Server) ImportStudents(w http.ResponseWriter, r *http.Request) {
+    if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil || mediaType != "text/csv" {
+        WriteJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be text/csv")
+        return
+    }
+
+    reader := csv.NewReader(r.Body)
+    reader.FieldsPerRecord = -1 // Rows with the wrong column count fail on their own
+    reader.TrimLeadingSpace = true
+
+    var rows []csvRow
+    failures := []importFailure{}
+    now := time.Now().UTC()
+    for first := true; ; first = false {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        var maxBytesErr *http.MaxBytesError
+        if errors.As(err, &maxBytesErr) {
+            WriteJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit))
+            return
+        }
+        var parseErr *csv.ParseError
+        if errors.As(err, &parseErr) {
+            WriteJSONError(w, http.StatusBadRequest, fmt.Sprintf("malformed CSV at line %d: %v", parseErr.Line, parseErr.Err))
+            return
+        }
+        if err != nil {
+            WriteJSONError(w, http.StatusBadRequest, "Invalid input")
+            return
+        }
+
+        line, _ := reader.FieldPos(0)
+        if first && isCSVHeader(record) {
+            continue
+        }
+        student, err := parseCSVRecord(record)
+        if err == nil {
+            s.defaults.apply(&student)
+            normalizeStudent(&student)
+            err = validateStudent(student)
+        }
+        if err != nil {
+            failures = append(failures, importFailure{Line: line, Error: err.Error()})
+            continue
+        }
+        student.CreatedAt = now
+        student.UpdatedAt = now
+        student.Active = true
+        rows = append(rows, csvRow{line: line, student: student})
+    }
+
+    imported := 0
+    if len(rows) > 0 {
+        students := make([]Student, len(rows))
+        for i, row := range rows {
+            students[i] = row.student
+        }
+        release, ok := s.reserveCapacity(w, r, len(students))
+        if !ok {
+            return
+        }
+        _, itemErrs, err := s.store.CreateMany(r.Context(), students)
+        release()
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        for i, itemErr := range itemErrs {
+            if itemErr != nil {
+                failures = append(failures, importFailure{Line: rows[i].line, Error: itemErr.Error()})
+                continue
+            }
+            imported++
+        }
+        // Store rejections were appended after the validation failures
+        slices.SortFunc(failures, func(a, b importFailure) int { return a.Line - b.Line })
+    }
+
+    s.writeJSON(w, r, map[string]interface{}{
+        "imported": imported,
+        "failed":   failures,
+    })
+}
+
+// isCSVHeader reports whether record is the name,age,email header row
+func isCSVHeader(record []string) bool {
+    if len(record) != len(csvColumns) {
+        return false
+    }
+    for i, column := range csvColumns {
+        if !strings.EqualFold(strings.TrimSpace(record[i]), column) {
+            return false
+        }
+    }
+    return true
+}
+
+// parseCSVRecord turns a name,age,email record into a Student. An empty age
+// is left as zero for StudentDefaults to fill in, or validation to reject.
+func parseCSVRecord(record []string) (Student, error) {
+    if len(record) != len(csvColumns) {
+        return Student{}, fmt.Errorf("expected %d columns (%s), got %d", len(csvColumns), strings.Join(csvColumns, ","), len(record))
+    }
+    var age int
+    if ageCell := strings.TrimSpace(record[1]); ageCell != "" {
+        var err error
+        if age, err = strconv.Atoi(ageCell); err != nil {
+            return Student{}, errors.New("age must be a number")
+        }
+        // Zero would read as omitted and pick up the default
+        if age == 0 {
+            return Student{}, fmt.Errorf("age must be between %d and %d", minAge, maxAge)
+        }
+    }
+    return Student{Name: record[0], Age: age, Email: strings.TrimSpace(record[2])}, nil
+}
+
+// ExportStudents handles GET /students/export, writing the students matching
+// the list endpoint's filter and sort parameters as a CSV attachment
+func (s *Server) ExportStudents(w http.ResponseWriter, r *http.Request) {
+    compare, err := parseSort(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    filter, err := parseFilter(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    studentList = filterStudents(studentList, filter)
+    sortStudents(studentList, compare)
+
+    w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+    w.Header().Set("Content-Disposition", `attachment; filename="students.csv"`)
+
+    // Rows go straight to the response through the csv.Writer's buffer
+    // rather than being assembled in memory first
+    writer := csv.NewWriter(w)
+    writer.Write(csvExportColumns)
+    for _, student := range studentList {
+        deletedAt := ""
+        if student.DeletedAt != nil {
+            deletedAt = student.DeletedAt.Format(time.RFC3339Nano)
+        }
+        writer.Write([]string{
+            string(student.ID),
+            student.Name,
+            strconv.Itoa(student.Age),
+            student.Email,
+            strconv.FormatBool(student.Active),
+            student.CreatedAt.Format(time.RFC3339Nano),
+            student.UpdatedAt.Format(time.RFC3339Nano),
+            deletedAt,
+        })
+    }
+    writer.Flush()
+    if err := writer.Error(); err != nil {
+        slog.Warn("CSV export aborted", "err", err)
+    }
+}
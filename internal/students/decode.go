@@ -0,0 +1,217 @@
+package students
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// decodeJSONBody validates the request body against schema and decodes it
+// into dst. Requests not sent as JSON get 415, bodies cut off by
+// http.MaxBytesReader get 413, and empty bodies and malformed JSON get 400;
+// schema violations get 422 with every problem listed. On failure it writes
+// the response and returns false.
+func (s *Server) decodeJSONBody(w http.ResponseWriter, r *http.Request, schema *jsonschema.Schema, dst interface{}) bool {
+    if !isJSONContentType(r.Header.Get("Content-Type")) {
+        WriteJSONError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+        return false
+    }
+    return s.decodeBody(w, r, schema, dst)
+}
+
+// decodeBody is decodeJSONBody for callers that have already checked the
+// Content-Type
+func (s *Server) decodeBody(w http.ResponseWriter, r *http.Request, schema *jsonschema.Schema, dst interface{}) bool {
+    body, ok := readBody(w, r)
+    if !ok {
+        return false
+    }
+
+    fieldErrs, err := validateAgainstSchema(schema, body)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, describeDecodeError(err))
+        return false
+    }
+    if len(fieldErrs) > 0 {
+        writeValidationError(w, fieldErrs)
+        return false
+    }
+    if err := decodeStrict(body, dst); err != nil {
+        WriteJSONError(w, http.StatusBadRequest, describeDecodeError(err))
+        return false
+    }
+    return true
+}
+
+// decodeMergePatch reads a JSON Merge Patch (RFC 7386) body into patch.
+// Student has no nested objects, so merging comes down to replacing each
+// field the patch names: a null clears the field, leaving its zero value for
+// validation to judge, and omitted fields are left alone.
+func (s *Server) decodeMergePatch(w http.ResponseWriter, r *http.Request, patch *studentPatch) bool {
+    var fields map[string]json.RawMessage
+    if !s.decodeBody(w, r, mergePatchSchema, &fields) {
+        return false
+    }
+
+    for field, value := range fields {
+        // Unmarshalling null into a fresh pointer leaves it pointing at zero
+        var err error
+        switch field {
+        case "name":
+            patch.Name = new(string)
+            err = json.Unmarshal(value, patch.Name)
+        case "age":
+            patch.Age = new(int)
+            err = json.Unmarshal(value, patch.Age)
+        case "email":
+            patch.Email = new(string)
+            err = json.Unmarshal(value, patch.Email)
+        }
+        if err != nil {
+            WriteJSONError(w, http.StatusBadRequest, describeDecodeError(err))
+            return false
+        }
+    }
+    return true
+}
+
+// readBody reads the whole request body, answering 413 if it is cut off by
+// http.MaxBytesReader. On failure it writes the response and returns false.
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+    body, err := io.ReadAll(r.Body)
+    var maxBytesErr *http.MaxBytesError
+    if errors.As(err, &maxBytesErr) {
+        WriteJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBytesErr.Limit))
+        return nil, false
+    }
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Failed to read request body")
+        return nil, false
+    }
+    return body, true
+}
+
+// validateAgainstSchema parses data and checks it against schema, returning
+// any violations. The error is only set when data isn't valid JSON.
+func validateAgainstSchema(schema *jsonschema.Schema, data []byte) (ValidationErrors, error) {
+    doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+    if err != nil {
+        return nil, err
+    }
+    normalizeEmailFields(doc)
+    if err := schema.Validate(doc); err != nil {
+        return schemaFieldErrors(err), nil
+    }
+    return nil, nil
+}
+
+// normalizeEmailFields applies normalizeEmail to the email fields of a
+// decoded body, or of each object in an array body, so the schema judges
+// emails the way they will be stored: " Alice@Example.com " is fine.
+func normalizeEmailFields(doc interface{}) {
+    switch v := doc.(type) {
+    case map[string]interface{}:
+        if email, ok := v["email"].(string); ok {
+            v["email"] = normalizeEmail(email)
+        }
+    case []interface{}:
+        for _, item := range v {
+            normalizeEmailFields(item)
+        }
+    }
+}
+
+// decodeStrict decodes data into dst, rejecting unknown fields. Once a
+// schema has vetted data this only fails on values Go can't represent, such
+// as 1.5 for an int.
+func decodeStrict(data []byte, dst interface{}) error {
+    decoder := json.NewDecoder(bytes.NewReader(data))
+    decoder.DisallowUnknownFields()
+    return decoder.Decode(dst)
+}
+
+// isJSONContentType reports whether a Content-Type header value is
+// application/json, optionally with a charset parameter
+func isJSONContentType(contentType string) bool {
+    return hasMediaType(contentType, "application/json")
+}
+
+// isMergePatchContentType reports whether a Content-Type header value is
+// application/merge-patch+json, optionally with a charset parameter
+func isMergePatchContentType(contentType string) bool {
+    return hasMediaType(contentType, "application/merge-patch+json")
+}
+
+// hasMediaType reports whether a Content-Type header value is mediaType,
+// allowing no parameters other than charset
+func hasMediaType(contentType, mediaType string) bool {
+    got, params, err := mime.ParseMediaType(contentType)
+    if err != nil || got != mediaType {
+        return false
+    }
+    for name := range params {
+        if name != "charset" {
+            return false
+        }
+    }
+    return true
+}
+
+// describeDecodeError turns a json.Decoder error into a message fit for
+// clients
+func describeDecodeError(err error) string {
+    var syntaxErr *json.SyntaxError
+    var typeErr *json.UnmarshalTypeError
+    switch {
+    case errors.Is(err, io.EOF):
+        // Nothing but whitespace before the end of the body
+        return "request body is required"
+    case errors.As(err, &syntaxErr):
+        return fmt.Sprintf("malformed JSON at position %d", syntaxErr.Offset)
+    case errors.Is(err, io.ErrUnexpectedEOF):
+        return "malformed JSON: unexpected end of body"
+    case errors.As(err, &typeErr):
+        if typeErr.Field == "" {
+            return fmt.Sprintf("request body must be a JSON %s", jsonTypeName(typeErr.Type))
+        }
+        name := jsonTypeName(typeErr.Type)
+        article := "a"
+        if strings.ContainsRune("aeiou", rune(name[0])) {
+            article = "an"
+        }
+        return fmt.Sprintf("%s must be %s %s", typeErr.Field, article, name)
+    case strings.HasPrefix(err.Error(), "json: unknown field "):
+        // The decoder has no typed error for this, only the message
+        return "unknown field: " + strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+    }
+    return "Invalid input"
+}
+
+// jsonTypeName names the JSON type that decodes into t
+func jsonTypeName(t reflect.Type) string {
+    switch t.Kind() {
+    case reflect.Pointer:
+        return jsonTypeName(t.Elem())
+    case reflect.Bool:
+        return "boolean"
+    case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+        reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+        // Includes numbers like 20.0 or 1e3, which Go won't put in an int
+        return "integer"
+    case reflect.Float32, reflect.Float64:
+        return "number"
+    case reflect.String:
+        return "string"
+    case reflect.Slice, reflect.Array:
+        return "array"
+    }
+    return "object"
+}
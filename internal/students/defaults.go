@@ -0,0 +1,28 @@
+package students
+
+import "fmt"
+
+// StudentDefaults holds values filled in for fields that a create request
+// leaves out. They are applied before normalization and validation, so a
+// student built from defaults is checked like any other. Only creates get
+// defaults: a PUT replaces the whole student and must send every field. A
+// zero field means no default.
+type StudentDefaults struct {
+    Age int
+}
+
+// Validate reports whether the defaults would make valid students
+func (d StudentDefaults) Validate() error {
+    if d.Age != 0 && (d.Age < minAge || d.Age > maxAge) {
+        return fmt.Errorf("age must be between %d and %d", minAge, maxAge)
+    }
+    return nil
+}
+
+// apply fills in the fields left out of s
+func (d StudentDefaults) apply(s *Student) {
+    // The schema rejects an explicit 0, so here it can only mean omitted
+    if s.Age == 0 {
+        s.Age = d.Age
+    }
+}
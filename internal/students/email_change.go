@@ -0,0 +1,183 @@
+package students
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// emailChangeTTL is how long an email change can be confirmed after it was
+// requested
+const emailChangeTTL = 24 * time.Hour
+
+// Reasons a confirmation is refused, checked inside the store update
+var (
+    errNoEmailChange      = errors.New("No email change is pending")
+    errEmailTokenInvalid  = errors.New("Invalid email change token")
+    errEmailChangeExpired = errors.New("Email change request has expired")
+)
+
+// emailChangeRequest is the body of POST /students/{id}/email-change
+type emailChangeRequest struct {
+    Email string `json:"email"`
+}
+
+// emailChangeConfirmation is the body of POST /students/{id}/email-change/confirm
+type emailChangeConfirmation struct {
+    Token string `json:"token"`
+}
+
+// pendingEmailChange is the response to a requested email change. It never
+// carries the token, which only the owner of the new email should see.
+type pendingEmailChange struct {
+    PendingEmail string    `json:"pending_email"`
+    ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// EmailChangeNotifier delivers the token confirming an email change to the
+// new address, e.g. by mail
+type EmailChangeNotifier interface {
+    SendEmailChangeToken(ctx context.Context, student Student, email, token string) error
+}
+
+// logEmailChangeNotifier is the default EmailChangeNotifier. It only writes
+// the token to the debug log, which is enough for development but delivers
+// nothing.
+type logEmailChangeNotifier struct{}
+
+func (logEmailChangeNotifier) SendEmailChangeToken(ctx context.Context, student Student, email, token string) error {
+    slog.DebugContext(ctx, "Email change token", "student_id", student.ID, "email", email, "token", token)
+    return nil
+}
+
+// RequestEmailChange handles POST /students/{id}/email-change, recording the
+// new email as pending, sending the token that confirms it through the
+// server's EmailChangeNotifier and answering 202. The current email keeps
+// working until then. Requesting another change replaces any pending one and
+// its token.
+func (s *Server) RequestEmailChange(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    var req emailChangeRequest
+    if !s.decodeJSONBody(w, r, emailChangeSchema, &req) {
+        return
+    }
+    email := normalizeEmail(req.Email)
+    if !validEmail(email) {
+        writeValidationError(w, ValidationErrors{{Field: "email", Message: "must be a valid email"}})
+        return
+    }
+
+    token, err := newEmailChangeToken()
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if email == student.Email {
+            return ValidationErrors{{Field: "email", Message: "must differ from the current email"}}
+        }
+        student.PendingEmail = email
+        student.emailTokenHash = hashEmailChangeToken(token)
+        student.emailTokenExpires = time.Now().UTC().Add(emailChangeTTL)
+        student.UpdatedAt = time.Now().UTC()
+        return nil
+    })
+    if err != nil {
+        writeModifyError(w, err)
+        return
+    }
+    s.history.record(actionEmailChangeRequest, student)
+    // The change stays pending if this fails; asking again sends a new token
+    if err := s.emailNotifier.SendEmailChangeToken(r.Context(), student, email, token); err != nil {
+        slog.Error("Failed to send email change token", "student_id", id, "err", err)
+        WriteJSONError(w, http.StatusBadGateway, "Could not send the email change token; request the change again")
+        return
+    }
+    s.writeJSONStatus(w, r, http.StatusAccepted, pendingEmailChange{PendingEmail: email, ExpiresAt: student.emailTokenExpires})
+}
+
+// ConfirmEmailChange handles POST /students/{id}/email-change/confirm,
+// replacing the student's email with the pending one if the token matches
+// and hasn't expired. An email taken by someone else in the meantime gets
+// 409 like any other update.
+func (s *Server) ConfirmEmailChange(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    var req emailChangeConfirmation
+    if !s.decodeJSONBody(w, r, emailConfirmSchema, &req) {
+        return
+    }
+
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if student.PendingEmail == "" {
+            return errNoEmailChange
+        }
+        if subtle.ConstantTimeCompare([]byte(hashEmailChangeToken(req.Token)), []byte(student.emailTokenHash)) != 1 {
+            return errEmailTokenInvalid
+        }
+        if time.Now().After(student.emailTokenExpires) {
+            return errEmailChangeExpired
+        }
+        student.Email = student.PendingEmail
+        student.PendingEmail = ""
+        student.emailTokenHash = ""
+        student.emailTokenExpires = time.Time{}
+        student.UpdatedAt = time.Now().UTC()
+        return nil
+    })
+    switch {
+    case errors.Is(err, errNoEmailChange):
+        WriteJSONError(w, http.StatusConflict, err.Error())
+        return
+    case errors.Is(err, errEmailTokenInvalid):
+        WriteJSONError(w, http.StatusForbidden, err.Error())
+        return
+    case errors.Is(err, errEmailChangeExpired):
+        WriteJSONError(w, http.StatusGone, err.Error())
+        return
+    case err != nil:
+        writeStoreError(w, err)
+        return
+    }
+    s.summaries.invalidate(id)
+    s.history.record(actionEmailChange, student)
+    w.Header().Set("ETag", studentETag(student))
+    s.writeJSON(w, r, student)
+}
+
+// newEmailChangeToken returns a random token for confirming an email change
+func newEmailChangeToken() (string, error) {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(b), nil
+}
+
+// hashEmailChangeToken returns the form a token is stored in, so a leaked
+// database can't be used to confirm changes
+func hashEmailChangeToken(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
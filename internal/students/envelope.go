@@ -0,0 +1,52 @@
+package students
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type contextKey int
+
+const requestIDKey contextKey = iota
+
+// WithRequestID returns a copy of ctx carrying the ID of the request it
+// belongs to
+func WithRequestID(ctx context.Context, id string) context.Context {
+    return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the ID stored by WithRequestID, or ""
+func RequestIDFromContext(ctx context.Context) string {
+    id, _ := ctx.Value(requestIDKey).(string)
+    return id
+}
+
+// envelope wraps a response body for clients that ask for metadata with it
+type envelope struct {
+    Data interface{}  `json:"data"`
+    Meta envelopeMeta `json:"meta"`
+}
+
+type envelopeMeta struct {
+    RequestID string    `json:"request_id"`
+    Timestamp time.Time `json:"timestamp"`
+}
+
+// wantsEnvelope reports whether the client opted into enveloped responses
+// with an X-Envelope: true header or ?envelope=true. Bare bodies remain the
+// default so existing clients are unaffected.
+func wantsEnvelope(r *http.Request) bool {
+    return r.Header.Get("X-Envelope") == "true" || r.URL.Query().Get("envelope") == "true"
+}
+
+// wrapEnvelope returns v wrapped in an envelope if r asked for one
+func wrapEnvelope(r *http.Request, v interface{}) interface{} {
+    if !wantsEnvelope(r) {
+        return v
+    }
+    return envelope{
+        Data: v,
+        Meta: envelopeMeta{RequestID: RequestIDFromContext(r.Context()), Timestamp: time.Now().UTC()},
+    }
+}
@@ -0,0 +1,53 @@
+package students
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// errIfMatchFailed is returned from inside a store update whose If-Match
+// precondition doesn't hold against the stored student
+var errIfMatchFailed = errors.New("Student has been modified; fetch it again and retry")
+
+// studentETag returns a strong entity tag for the JSON representation of s,
+// so it changes whenever any field, timestamps included, does
+func studentETag(s Student) string {
+    return jsonETag(s)
+}
+
+// jsonETag returns a strong entity tag for the JSON encoding of v, which
+// must be marshalable
+func jsonETag(v interface{}) string {
+    data, _ := json.Marshal(v)
+    sum := sha256.Sum256(data)
+    return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagListMatches reports whether an If-None-Match or If-Match header value
+// lists etag or is "*". With weak comparison, as for If-None-Match, weak tags
+// (W/"...") compare equal to their strong counterparts; If-Match needs strong
+// comparison, under which a weak tag matches nothing (RFC 9110, 13.1.1).
+func etagListMatches(header, etag string, weak bool) bool {
+    for _, candidate := range strings.Split(header, ",") {
+        candidate = strings.TrimSpace(candidate)
+        if weak {
+            candidate = strings.TrimPrefix(candidate, "W/")
+        }
+        if candidate == "*" || candidate == etag {
+            return true
+        }
+    }
+    return false
+}
+
+// ifMatchHolds enforces an If-Match precondition against the current state
+// of a student. Requests without If-Match always pass; callers checking it
+// inside a store update return errIfMatchFailed if it doesn't hold.
+func ifMatchHolds(r *http.Request, current Student) bool {
+    ifMatch := r.Header.Get("If-Match")
+    return ifMatch == "" || etagListMatches(ifMatch, studentETag(current), false)
+}
@@ -0,0 +1,56 @@
+package students
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// studentFields are the JSON field names of a Student that ?fields= accepts
+var studentFields = []string{"id", "name", "age", "email", "active", "created_at", "updated_at", "deleted_at", "pending_email"}
+
+// parseFields reads the fields query parameter, e.g. "id,name", returning
+// nil when it is absent so callers send whole students
+func parseFields(r *http.Request) ([]string, error) {
+    v := r.URL.Query().Get("fields")
+    if v == "" {
+        return nil, nil
+    }
+    var fields []string
+    for _, field := range strings.Split(v, ",") {
+        field = strings.TrimSpace(field)
+        if !slices.Contains(studentFields, field) {
+            return nil, fmt.Errorf("unknown field %q", field)
+        }
+        fields = append(fields, field)
+    }
+    return fields, nil
+}
+
+// projectStudent returns s as a JSON object holding only fields. Fields that
+// would be omitted from the full student, such as deleted_at on a live one,
+// are omitted here too.
+func projectStudent(s Student, fields []string) map[string]interface{} {
+    data, _ := json.Marshal(s) // Student has no fields that can fail to marshal
+    var all map[string]json.RawMessage
+    json.Unmarshal(data, &all)
+
+    projected := make(map[string]interface{}, len(fields))
+    for _, field := range fields {
+        if value, ok := all[field]; ok {
+            projected[field] = value
+        }
+    }
+    return projected
+}
+
+// projectStudents applies projectStudent to each of students
+func projectStudents(students []Student, fields []string) []map[string]interface{} {
+    projected := make([]map[string]interface{}, len(students))
+    for i, s := range students {
+        projected[i] = projectStudent(s, fields)
+    }
+    return projected
+}
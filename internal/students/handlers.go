@@ -0,0 +1,997 @@
+package students
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Server holds the dependencies shared by the HTTP handlers
+type Server struct {
+    store         StudentStore
+    ollama        *OllamaClient
+    summaries     *summaryCache
+    inflight      summaryFlights // Summaries being generated, by studentHash
+    history       *studentHistory
+    defaults      StudentDefaults
+    idempotency   *idempotencyCache
+    emailNotifier EmailChangeNotifier
+    prettyJSON    bool
+    pageSize      int  // Page size when a list request has no limit
+    maxPageSize   int  // Largest limit a list request gets
+    maxStudents   int  // Zero means no cap
+    countDeleted  bool // Whether soft-deleted students count towards maxStudents
+    capacityMu    sync.Mutex
+}
+
+// NewServer returns a Server backed by the given store and Ollama client
+func NewServer(store StudentStore, ollama *OllamaClient) *Server {
+    return &Server{
+        store:         store,
+        ollama:        ollama,
+        summaries:     newSummaryCache(DefaultSummaryCacheTTL),
+        history:       newStudentHistory(DefaultHistoryLimit),
+        idempotency:   newIdempotencyCache(),
+        emailNotifier: logEmailChangeNotifier{},
+        pageSize:      DefaultPageSize,
+        maxPageSize:   DefaultMaxPageSize,
+    }
+}
+
+// SetEmailChangeNotifier sets how email change tokens reach the new address.
+// By default they are only written to the debug log.
+func (s *Server) SetEmailChangeNotifier(n EmailChangeNotifier) {
+    s.emailNotifier = n
+}
+
+// SetPrettyJSON makes every JSON response body indented, as if each request
+// passed ?pretty=true. Responses are compact by default.
+func (s *Server) SetPrettyJSON(pretty bool) {
+    s.prettyJSON = pretty
+}
+
+// SetPageSizes sets the page size used when a list request has no limit and
+// the largest limit a request is given. Callers must ensure
+// 1 <= defaultSize <= maxSize.
+func (s *Server) SetPageSizes(defaultSize, maxSize int) {
+    s.pageSize = defaultSize
+    s.maxPageSize = maxSize
+}
+
+// SetDefaults sets the values filled in for fields left out of new
+// students
+func (s *Server) SetDefaults(defaults StudentDefaults) {
+    s.defaults = defaults
+}
+
+// SetSummaryCacheTTL sets how long generated summaries are cached; zero
+// caches them until the student changes. It must be called before the
+// server starts handling requests.
+func (s *Server) SetSummaryCacheTTL(ttl time.Duration) {
+    s.summaries = newSummaryCache(ttl)
+}
+
+// EvictExpiredSummaries removes expired summaries from the cache
+// periodically, returning once ctx is done
+func (s *Server) EvictExpiredSummaries(ctx context.Context) {
+    s.summaries.runEviction(ctx)
+}
+
+// SetHistoryLimit sets how many versions of each student
+// GET /students/{id}/history keeps; zero turns history off. It must be
+// called before the server starts handling requests.
+func (s *Server) SetHistoryLimit(limit int) {
+    s.history = newStudentHistory(limit)
+}
+
+// writeJSON encodes v as the response body, indented with json.MarshalIndent
+// when pretty printing is on for the server or requested with ?pretty=true,
+// and wrapped in an envelope when the request asks for one. Error bodies stay
+// compact and bare.
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, v interface{}) {
+    s.writeJSONStatus(w, r, http.StatusOK, v)
+}
+
+// writeJSONStatus is writeJSON for responses with a status other than 200.
+// The Content-Type is application/json unless the caller set one.
+func (s *Server) writeJSONStatus(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+    if w.Header().Get("Content-Type") == "" {
+        w.Header().Set("Content-Type", "application/json")
+    }
+    w.WriteHeader(status)
+    v = wrapEnvelope(r, v)
+    if !s.prettyJSON && r.URL.Query().Get("pretty") != "true" {
+        json.NewEncoder(w).Encode(v)
+        return
+    }
+    data, err := json.MarshalIndent(v, "", "  ")
+    if err != nil {
+        slog.Error("Failed to encode response", "err", err)
+        return
+    }
+    w.Write(append(data, '\n'))
+}
+
+// WriteJSONError writes an error response as {"error": msg}
+func WriteJSONError(w http.ResponseWriter, status int, msg string) {
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(status)
+    json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+// negotiateContentType returns the offer with the highest q-value in the
+// Accept header. Wildcards and unknown types are ignored, so the first offer
+// acts as the default.
+func negotiateContentType(r *http.Request, offers ...string) string {
+    best, bestQ := offers[0], -1.0
+    for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+        mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+        if err != nil {
+            continue
+        }
+        q := 1.0
+        if v, ok := params["q"]; ok {
+            if q, err = strconv.ParseFloat(v, 64); err != nil {
+                continue
+            }
+        }
+        for _, offer := range offers {
+            if mediaType == offer && q > bestQ {
+                best, bestQ = offer, q
+            }
+        }
+    }
+    return best
+}
+
+// writeValidationError sends ValidationErrors as {"errors": [...]} with
+// status 422, and any other error as a plain 400
+func writeValidationError(w http.ResponseWriter, err error) {
+    var fieldErrs ValidationErrors
+    if !errors.As(err, &fieldErrs) {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    w.Header().Set("Content-Type", "application/json")
+    w.WriteHeader(http.StatusUnprocessableEntity)
+    json.NewEncoder(w).Encode(map[string]ValidationErrors{"errors": fieldErrs})
+}
+
+// writeStoreError maps a store failure onto an HTTP error response
+func writeStoreError(w http.ResponseWriter, err error) {
+    if errors.Is(err, ErrNotFound) {
+        WriteJSONError(w, http.StatusNotFound, "Student not found")
+        return
+    }
+    if errors.Is(err, ErrDuplicateID) || errors.Is(err, ErrDuplicateEmail) {
+        WriteJSONError(w, http.StatusConflict, err.Error())
+        return
+    }
+    // The client went away or the request ran out of time mid-query
+    if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+        slog.Info("Store call abandoned", "err", err)
+        WriteJSONError(w, http.StatusServiceUnavailable, "Request was cancelled or timed out")
+        return
+    }
+    slog.Error("Store error", "err", err)
+    WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// writeModifyError maps an error from a store.Modify callback, or from the
+// store itself, onto an HTTP error response
+func writeModifyError(w http.ResponseWriter, err error) {
+    var fieldErrs ValidationErrors
+    switch {
+    case errors.As(err, &fieldErrs):
+        writeValidationError(w, err)
+    case errors.Is(err, errIfMatchFailed):
+        WriteJSONError(w, http.StatusPreconditionFailed, err.Error())
+    default:
+        writeStoreError(w, err)
+    }
+}
+
+// pathID parses the {id} route variable in the store's ID format
+func (s *Server) pathID(r *http.Request) (ID, error) {
+    return s.store.IDFormat().parse(mux.Vars(r)["id"])
+}
+
+// getStudent loads a student from the store, treating soft-deleted students
+// as missing unless includeDeleted is set
+func (s *Server) getStudent(ctx context.Context, id ID, includeDeleted bool) (Student, error) {
+    student, err := s.store.GetByID(ctx, id)
+    if err != nil {
+        return Student{}, err
+    }
+    if student.DeletedAt != nil && !includeDeleted {
+        return Student{}, ErrNotFound
+    }
+    return student, nil
+}
+
+// CreateStudent handles POST /students to create a new student. The ID is
+// generated unless the body supplies one. Clients can send an
+// Idempotency-Key header to retry safely without creating duplicates.
+func (s *Server) CreateStudent(w http.ResponseWriter, r *http.Request) {
+    s.idempotent(w, r, s.createStudent)
+}
+
+func (s *Server) createStudent(w http.ResponseWriter, r *http.Request) {
+    student, ok := s.decodeNewStudent(w, r)
+    if !ok {
+        return
+    }
+
+    now := time.Now().UTC()
+    student.CreatedAt = now
+    student.UpdatedAt = now
+    student.DeletedAt = nil
+    student.Active = true
+
+    release, ok := s.reserveCapacity(w, r, 1)
+    if !ok {
+        return
+    }
+    student, err := s.store.Create(r.Context(), student)
+    release()
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    s.writeCreated(w, r, student)
+}
+
+// decodeNewStudent reads, normalizes and validates the body of a create
+// request. If it fails, the error response has been written and ok is false.
+func (s *Server) decodeNewStudent(w http.ResponseWriter, r *http.Request) (student Student, ok bool) {
+    var input newStudentInput
+    if !s.decodeJSONBody(w, r, newStudentSchema, &input) {
+        return Student{}, false
+    }
+    student, err := input.student(s.store.IDFormat())
+    if err != nil {
+        writeValidationError(w, err)
+        return Student{}, false
+    }
+    s.defaults.apply(&student)
+    normalizeStudent(&student)
+    if err := validateStudent(student); err != nil {
+        writeValidationError(w, err)
+        return Student{}, false
+    }
+    return student, true
+}
+
+// ValidateStudent handles POST /students/validate, checking a body as
+// POST /students would without storing anything. A valid body gets 200 with
+// {"valid": true}; an invalid one gets the same error response a create
+// would. Conflicts with stored students, such as a taken email, aren't
+// checked since they can change before the real request is made.
+func (s *Server) ValidateStudent(w http.ResponseWriter, r *http.Request) {
+    if _, ok := s.decodeNewStudent(w, r); !ok {
+        return
+    }
+    s.writeJSON(w, r, map[string]bool{"valid": true})
+}
+
+// writeCreated sends the 201 response for a newly created student, with a
+// Location header pointing at it
+func (s *Server) writeCreated(w http.ResponseWriter, r *http.Request, student Student) {
+    w.Header().Set("Location", fmt.Sprintf("/students/%s", student.ID))
+    s.writeJSONStatus(w, r, http.StatusCreated, student)
+}
+
+// bulkResult reports the outcome for one item of a bulk create or update
+type bulkResult struct {
+    Index   int      `json:"index"`
+    Status  int      `json:"status"`
+    Student *Student `json:"student,omitempty"`
+    Error   string   `json:"error,omitempty"`
+    // Errors lists every problem with an invalid item
+    Errors ValidationErrors `json:"errors,omitempty"`
+}
+
+// BulkCreateStudents handles POST /students/bulk to create many students at
+// once. Valid items are stored even if others fail validation; the 207
+// response lists the outcome of every item in request order.
+func (s *Server) BulkCreateStudents(w http.ResponseWriter, r *http.Request) {
+    // Items are checked one at a time so one bad item doesn't fail the batch
+    var batch []json.RawMessage
+    if !s.decodeJSONBody(w, r, newStudentsSchema, &batch) {
+        return
+    }
+
+    results := make([]bulkResult, len(batch))
+    var valid []Student
+    var validIndexes []int
+    now := time.Now().UTC()
+    for i, item := range batch {
+        results[i].Index = i
+        fieldErrs, _ := validateAgainstSchema(newStudentSchema, item) // item is already known to be valid JSON
+        if len(fieldErrs) > 0 {
+            results[i].Status = http.StatusUnprocessableEntity
+            results[i].Errors = fieldErrs
+            continue
+        }
+        var input newStudentInput
+        if err := decodeStrict(item, &input); err != nil {
+            results[i].Status = http.StatusBadRequest
+            results[i].Error = describeDecodeError(err)
+            continue
+        }
+        student, err := input.student(s.store.IDFormat())
+        if err == nil {
+            s.defaults.apply(&student)
+            normalizeStudent(&student)
+            err = validateStudent(student)
+        }
+        if errors.As(err, &fieldErrs) {
+            results[i].Status = http.StatusUnprocessableEntity
+            results[i].Errors = fieldErrs
+            continue
+        }
+        student.CreatedAt = now
+        student.UpdatedAt = now
+        student.DeletedAt = nil
+        student.Active = true
+        valid = append(valid, student)
+        validIndexes = append(validIndexes, i)
+    }
+
+    createdCount := 0
+    if len(valid) > 0 {
+        release, ok := s.reserveCapacity(w, r, len(valid))
+        if !ok {
+            return
+        }
+        created, itemErrs, err := s.store.CreateMany(r.Context(), valid)
+        release()
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        for j, student := range created {
+            result := &results[validIndexes[j]]
+            if itemErrs[j] != nil {
+                result.Status = http.StatusConflict
+                result.Error = itemErrs[j].Error()
+                continue
+            }
+            result.Status = http.StatusCreated
+            result.Student = &student
+            createdCount++
+        }
+    }
+
+    s.writeJSONStatus(w, r, http.StatusMultiStatus, map[string]interface{}{
+        "created": createdCount,
+        "failed":  len(batch) - createdCount,
+        "results": results,
+    })
+}
+
+// GetStudents handles GET /students to retrieve a page of students
+func (s *Server) GetStudents(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Query().Has("ids") {
+        s.getStudentsByIDs(w, r)
+        return
+    }
+
+    limit, offset, err := s.parsePagination(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    compare, err := parseSort(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    filter, err := parseFilter(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    // A cursor takes precedence over offset, which is kept for compatibility
+    cursor, useCursor, err := parseCursor(r, s.store.IDFormat())
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    if useCursor && !sortsByID(r) {
+        WriteJSONError(w, http.StatusBadRequest, "cursor can only be used when sorting by id")
+        return
+    }
+    fields, err := parseFields(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    studentList = filterStudents(studentList, filter)
+    sortStudents(studentList, compare)
+
+    var page studentPage
+    if useCursor {
+        page = paginateAfter(studentList, limit, cursor)
+    } else {
+        page = paginate(studentList, limit, offset)
+    }
+    if sortsByID(r) {
+        page.setNextCursor()
+    }
+    if fields != nil {
+        // The outer Data field takes precedence over the embedded one
+        s.writeJSON(w, r, struct {
+            studentPage
+            Data []map[string]interface{} `json:"data"`
+        }{page, projectStudents(page.Data, fields)})
+        return
+    }
+    s.writeJSON(w, r, page)
+}
+
+// studentBatch is the response to GET /students?ids=...
+type studentBatch struct {
+    Data    []Student `json:"data"`
+    Missing []ID      `json:"missing"`
+}
+
+// getStudentsByIDs serves GET /students?ids=1,2,3, returning the requested
+// students in the order asked for and listing IDs that don't exist (or are
+// soft-deleted, unless include_deleted=true) under "missing"
+func (s *Server) getStudentsByIDs(w http.ResponseWriter, r *http.Request) {
+    ids, err := parseIDList(r.URL.Query().Get("ids"), s.store.IDFormat(), s.maxPageSize)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    fields, err := parseFields(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+
+    batch := studentBatch{Data: []Student{}, Missing: []ID{}}
+    for _, id := range ids {
+        student, err := s.getStudent(r.Context(), id, includeDeleted)
+        if errors.Is(err, ErrNotFound) {
+            batch.Missing = append(batch.Missing, id)
+            continue
+        }
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        batch.Data = append(batch.Data, student)
+    }
+    if fields != nil {
+        s.writeJSON(w, r, struct {
+            studentBatch
+            Data []map[string]interface{} `json:"data"`
+        }{batch, projectStudents(batch.Data, fields)})
+        return
+    }
+    s.writeJSON(w, r, batch)
+}
+
+// CountStudents handles GET /students/count, returning how many students
+// match the same filters GET /students accepts
+func (s *Server) CountStudents(w http.ResponseWriter, r *http.Request) {
+    filter, err := parseFilter(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    var count int
+    if filter.matchesAll() {
+        count, err = s.store.Count(r.Context())
+    } else {
+        var studentList []Student
+        studentList, err = s.store.GetAll(r.Context())
+        count = len(filterStudents(studentList, filter))
+    }
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.writeJSON(w, r, map[string]int{"count": count})
+}
+
+// GetStudentByID handles GET /students/{id} to retrieve a student by ID. The
+// response carries an ETag, and a matching If-None-Match yields 304. With
+// ?fields= only the listed fields are returned, under an ETag of their own.
+func (s *Server) GetStudentByID(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+    fields, err := parseFields(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    student, err := s.getStudent(r.Context(), id, r.URL.Query().Get("include_deleted") == "true")
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    var body interface{} = student
+    if fields != nil {
+        body = projectStudent(student, fields)
+    }
+    etag := jsonETag(body)
+    w.Header().Set("ETag", etag)
+    if etagListMatches(r.Header.Get("If-None-Match"), etag, true) {
+        w.WriteHeader(http.StatusNotModified)
+        return
+    }
+    s.writeJSON(w, r, body)
+}
+
+// UpdateStudentByID handles PUT /students/{id} to update a student by ID.
+// With ?upsert=true a missing student is created under that ID instead. An
+// If-Match header makes the update conditional on the student's ETag.
+func (s *Server) UpdateStudentByID(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    var updatedStudent Student
+    if !s.decodeJSONBody(w, r, studentSchema, &updatedStudent) {
+        return
+    }
+    if updatedStudent.ID != "" {
+        if bodyID, err := s.store.IDFormat().parse(string(updatedStudent.ID)); err != nil || bodyID != id {
+            WriteJSONError(w, http.StatusBadRequest, "id in body does not match path")
+            return
+        }
+    }
+    normalizeStudent(&updatedStudent)
+    if err := validateStudent(updatedStudent); err != nil {
+        writeValidationError(w, err)
+        return
+    }
+
+    // Server-managed fields are carried over inside the store, so a change
+    // landing between a read and the write isn't lost
+    replacement := updatedStudent
+    updatedStudent, err = s.store.Modify(r.Context(), id, func(existing *Student) error {
+        if existing.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if !ifMatchHolds(r, *existing) {
+            return errIfMatchFailed
+        }
+        replacement.ID = id
+        replacement.CreatedAt = existing.CreatedAt
+        replacement.UpdatedAt = time.Now().UTC()
+        replacement.DeletedAt = existing.DeletedAt
+        replacement.Active = existing.Active
+        replacement.PendingEmail = existing.PendingEmail
+        replacement.emailTokenHash = existing.emailTokenHash
+        replacement.emailTokenExpires = existing.emailTokenExpires
+        *existing = replacement
+        return nil
+    })
+    if errors.Is(err, ErrNotFound) && r.URL.Query().Get("upsert") == "true" {
+        // An If-Match can never hold for a student that doesn't exist
+        if r.Header.Get("If-Match") != "" {
+            WriteJSONError(w, http.StatusPreconditionFailed, "Student does not exist")
+            return
+        }
+        s.createStudentAt(w, r, id, replacement)
+        return
+    }
+    if err != nil {
+        writeModifyError(w, err)
+        return
+    }
+    s.summaries.invalidate(id)
+    s.history.record(actionUpdate, updatedStudent)
+    w.Header().Set("ETag", studentETag(updatedStudent))
+    s.writeJSON(w, r, updatedStudent)
+}
+
+// createStudentAt stores an already validated student under the given ID and
+// writes the 201 response, for PUT upserts
+func (s *Server) createStudentAt(w http.ResponseWriter, r *http.Request, id ID, student Student) {
+    now := time.Now().UTC()
+    student.ID = id
+    student.CreatedAt = now
+    student.UpdatedAt = now
+    student.DeletedAt = nil
+    student.Active = true
+    student.PendingEmail = ""
+
+    release, ok := s.reserveCapacity(w, r, 1)
+    if !ok {
+        return
+    }
+    student, err := s.store.Create(r.Context(), student)
+    release()
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.writeCreated(w, r, student)
+}
+
+// PatchStudentByID handles PATCH /students/{id} to update only the given
+// fields, honouring If-Match like UpdateStudentByID
+func (s *Server) PatchStudentByID(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    var patch studentPatch
+    if isMergePatchContentType(r.Header.Get("Content-Type")) {
+        if !s.decodeMergePatch(w, r, &patch) {
+            return
+        }
+    } else if !s.decodeJSONBody(w, r, studentPatchSchema, &patch) {
+        return
+    }
+
+    // The patch is applied inside the store so concurrent changes to other
+    // fields, or a delete, aren't overwritten with what was read here
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if !ifMatchHolds(r, *student) {
+            return errIfMatchFailed
+        }
+        patch.apply(student)
+        normalizeStudent(student)
+        if err := validateStudent(*student); err != nil {
+            return err
+        }
+        student.UpdatedAt = time.Now().UTC()
+        return nil
+    })
+    if err != nil {
+        writeModifyError(w, err)
+        return
+    }
+    s.summaries.invalidate(id)
+    s.history.record(actionPatch, student)
+    w.Header().Set("ETag", studentETag(student))
+    s.writeJSON(w, r, student)
+}
+
+// bulkPatchItem is one element of a PATCH /students body
+type bulkPatchItem struct {
+    ID ID `json:"id"`
+    studentPatch
+}
+
+// BulkPatchStudents handles PATCH /students to partially update many
+// students at once, given a body like [{"id": 1, "age": 21}, ...]. The
+// updates are applied in one atomic step. Items that are invalid, name a
+// missing student or would take another student's email are skipped without
+// affecting the rest; the 207 response lists the outcome of every item in
+// request order.
+func (s *Server) BulkPatchStudents(w http.ResponseWriter, r *http.Request) {
+    // Items are checked one at a time so one bad item doesn't fail the batch
+    var batch []json.RawMessage
+    if !s.decodeJSONBody(w, r, patchesSchema, &batch) {
+        return
+    }
+
+    results := make([]bulkResult, len(batch))
+    var ids []ID
+    var patches []studentPatch
+    var indexes []int
+    for i, item := range batch {
+        results[i].Index = i
+        fieldErrs, _ := validateAgainstSchema(patchItemSchema, item) // item is already known to be valid JSON
+        if len(fieldErrs) > 0 {
+            results[i].Status = http.StatusUnprocessableEntity
+            results[i].Errors = fieldErrs
+            continue
+        }
+        var input bulkPatchItem
+        if err := decodeStrict(item, &input); err != nil {
+            results[i].Status = http.StatusBadRequest
+            results[i].Error = describeDecodeError(err)
+            continue
+        }
+        id, err := s.store.IDFormat().parse(string(input.ID))
+        if err != nil {
+            results[i].Status = http.StatusUnprocessableEntity
+            results[i].Errors = ValidationErrors{{Field: "id", Message: err.Error()}}
+            continue
+        }
+        ids = append(ids, id)
+        patches = append(patches, input.studentPatch)
+        indexes = append(indexes, i)
+    }
+
+    updatedCount := 0
+    if len(ids) > 0 {
+        now := time.Now().UTC()
+        modified, itemErrs, err := s.store.ModifyMany(r.Context(), ids, func(j int, student *Student) error {
+            if student.DeletedAt != nil {
+                return ErrNotFound
+            }
+            patches[j].apply(student)
+            normalizeStudent(student)
+            if err := validateStudent(*student); err != nil {
+                return err
+            }
+            student.UpdatedAt = now
+            return nil
+        })
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        for j, student := range modified {
+            result := &results[indexes[j]]
+            var fieldErrs ValidationErrors
+            switch {
+            case errors.As(itemErrs[j], &fieldErrs):
+                result.Status = http.StatusUnprocessableEntity
+                result.Errors = fieldErrs
+            case errors.Is(itemErrs[j], ErrNotFound):
+                result.Status = http.StatusNotFound
+                result.Error = "Student not found"
+            case itemErrs[j] != nil:
+                result.Status = http.StatusConflict
+                result.Error = itemErrs[j].Error()
+            default:
+                s.summaries.invalidate(student.ID)
+                s.history.record(actionPatch, student)
+                result.Status = http.StatusOK
+                result.Student = &student
+                updatedCount++
+            }
+        }
+    }
+
+    s.writeJSONStatus(w, r, http.StatusMultiStatus, map[string]interface{}{
+        "updated": updatedCount,
+        "failed":  len(batch) - updatedCount,
+        "results": results,
+    })
+}
+
+// DeleteStudentByID handles DELETE /students/{id} to soft-delete a student by ID
+func (s *Server) DeleteStudentByID(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        now := time.Now().UTC()
+        student.DeletedAt = &now
+        student.UpdatedAt = now
+        return nil
+    })
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.summaries.invalidate(id)
+    s.history.record(actionDelete, student)
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// DeleteAllStudents handles DELETE /students?confirm=true, permanently
+// removing every student. Without the confirmation it is refused with 400 so
+// a stray request can't wipe the store.
+func (s *Server) DeleteAllStudents(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Query().Get("confirm") != "true" {
+        WriteJSONError(w, http.StatusBadRequest, "Deleting all students requires confirm=true")
+        return
+    }
+    if _, err := s.store.DeleteAll(r.Context()); err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.summaries.clear()
+    s.history.clear()
+    w.WriteHeader(http.StatusNoContent)
+}
+
+// CelebrateBirthday handles POST /students/{id}/birthday, adding one to the
+// student's age. The increment happens inside the store, so concurrent
+// calls each count rather than overwriting one another.
+func (s *Server) CelebrateBirthday(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if student.Age >= maxAge {
+            return ValidationErrors{{Field: "age", Message: fmt.Sprintf("must be at most %d", maxAge)}}
+        }
+        student.Age++
+        student.UpdatedAt = time.Now().UTC()
+        return nil
+    })
+    var fieldErrs ValidationErrors
+    if errors.As(err, &fieldErrs) {
+        writeValidationError(w, err)
+        return
+    }
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.summaries.invalidate(id)
+    s.history.record(actionBirthday, student)
+    w.Header().Set("ETag", studentETag(student))
+    s.writeJSON(w, r, student)
+}
+
+// ActivateStudent handles POST /students/{id}/activate, marking the student
+// active again. Activating an active student changes nothing.
+func (s *Server) ActivateStudent(w http.ResponseWriter, r *http.Request) {
+    s.setActive(w, r, true)
+}
+
+// DeactivateStudent handles POST /students/{id}/deactivate, marking the
+// student inactive without deleting it. Deactivating an inactive student
+// changes nothing.
+func (s *Server) DeactivateStudent(w http.ResponseWriter, r *http.Request) {
+    s.setActive(w, r, false)
+}
+
+// setActive sets the Active flag of the student named in the path and
+// responds with the result
+func (s *Server) setActive(w http.ResponseWriter, r *http.Request, active bool) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    changed := false
+    student, err := s.store.Modify(r.Context(), id, func(student *Student) error {
+        if student.DeletedAt != nil {
+            return ErrNotFound
+        }
+        if student.Active != active {
+            student.Active = active
+            student.UpdatedAt = time.Now().UTC()
+            changed = true
+        }
+        return nil
+    })
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    if changed {
+        action := actionDeactivate
+        if active {
+            action = actionActivate
+        }
+        s.history.record(action, student)
+    }
+    w.Header().Set("ETag", studentETag(student))
+    s.writeJSON(w, r, student)
+}
+
+// RestoreStudentByID handles POST /students/{id}/restore to undo a soft delete
+func (s *Server) RestoreStudentByID(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    student, err := s.getStudent(r.Context(), id, true)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    if student.DeletedAt != nil {
+        release := func() {}
+        if !s.countDeleted {
+            var ok bool
+            if release, ok = s.reserveCapacity(w, r, 1); !ok {
+                return
+            }
+        }
+        // Someone else may restore it first, in which case this changes nothing
+        restored := false
+        student, err = s.store.Modify(r.Context(), id, func(student *Student) error {
+            if student.DeletedAt != nil {
+                student.DeletedAt = nil
+                student.UpdatedAt = time.Now().UTC()
+                restored = true
+            }
+            return nil
+        })
+        release()
+        if err != nil {
+            writeStoreError(w, err)
+            return
+        }
+        if restored {
+            s.history.record(actionRestore, student)
+        }
+    }
+    s.writeJSON(w, r, student)
+}
+
+// CloneStudent handles POST /students/{id}/clone, creating a new student
+// with a fresh ID from the name, age and email of an existing one. An
+// optional body shaped like a PATCH overrides fields of the copy; since
+// emails are unique it usually needs to give at least a new email.
+func (s *Server) CloneStudent(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    var patch studentPatch
+    if r.ContentLength != 0 && !s.decodeJSONBody(w, r, studentPatchSchema, &patch) {
+        return
+    }
+
+    source, err := s.getStudent(r.Context(), id, false)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    now := time.Now().UTC()
+    student := Student{Name: source.Name, Age: source.Age, Email: source.Email, Active: true, CreatedAt: now, UpdatedAt: now}
+    patch.apply(&student)
+    normalizeStudent(&student)
+    if err := validateStudent(student); err != nil {
+        writeValidationError(w, err)
+        return
+    }
+
+    release, ok := s.reserveCapacity(w, r, 1)
+    if !ok {
+        return
+    }
+    student, err = s.store.Create(r.Context(), student)
+    release()
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.writeCreated(w, r, student)
+}
@@ -0,0 +1,35 @@
+package students
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// readinessTimeout bounds each dependency check so probes never hang
+const readinessTimeout = 2 * time.Second
+
+// Health handles GET /health, reporting that the process is up
+func (s *Server) Health(w http.ResponseWriter, r *http.Request) {
+    s.writeJSON(w, r, map[string]string{"status": "ok"})
+}
+
+// Ready handles GET /ready, additionally checking that the data store and
+// Ollama are reachable. It answers 503 with per-dependency details if not.
+func (s *Server) Ready(w http.ResponseWriter, r *http.Request) {
+    ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+    defer cancel()
+
+    checks := map[string]string{"store": "ok", "ollama": "ok"}
+    status, code := "ok", http.StatusOK
+    if err := s.store.Ping(ctx); err != nil {
+        checks["store"] = err.Error()
+        status, code = "unavailable", http.StatusServiceUnavailable
+    }
+    if err := s.ollama.Ping(ctx); err != nil {
+        checks["ollama"] = err.Error()
+        status, code = "unavailable", http.StatusServiceUnavailable
+    }
+
+    s.writeJSONStatus(w, r, code, map[string]interface{}{"status": status, "checks": checks})
+}
@@ -0,0 +1,107 @@
+package students
+
+import (
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultHistoryLimit is how many versions of each student are kept unless
+// Server.SetHistoryLimit says otherwise
+const DefaultHistoryLimit = 20
+
+// Actions recorded in a student's history
+const (
+    actionUpdate             = "update"
+    actionPatch              = "patch"
+    actionDelete             = "delete"
+    actionRestore            = "restore"
+    actionBirthday           = "birthday"
+    actionActivate           = "activate"
+    actionDeactivate         = "deactivate"
+    actionEmailChangeRequest = "email_change_requested"
+    actionEmailChange        = "email_changed"
+)
+
+// historyEntry is one version of a student, as it was right after a change
+type historyEntry struct {
+    Version   int       `json:"version"` // The store's count of changes, including ones no longer kept
+    Action    string    `json:"action"`
+    Timestamp time.Time `json:"timestamp"`
+    Student   Student   `json:"student"`
+}
+
+// studentHistory keeps the most recent versions of each changed student.
+// It lives in memory whatever the store, so it starts empty after a restart
+// even when the students themselves are kept in SQLite.
+type studentHistory struct {
+    mu      sync.Mutex
+    limit   int // Versions kept per student; zero disables recording
+    entries map[ID][]historyEntry
+}
+
+func newStudentHistory(limit int) *studentHistory {
+    return &studentHistory{limit: limit, entries: make(map[ID][]historyEntry)}
+}
+
+// record adds student, as saved by action, to its history, dropping the
+// oldest version once there are more than limit. Callers record after the
+// store has saved the change, so concurrent changes to one student may
+// arrive out of order; entries are kept in the order of the version the
+// store gave them instead.
+func (h *studentHistory) record(action string, student Student) {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    if h.limit <= 0 {
+        return
+    }
+
+    entry := historyEntry{
+        Version:   student.version,
+        Action:    action,
+        Timestamp: time.Now().UTC(),
+        Student:   student,
+    }
+    entries := h.entries[student.ID]
+    i, _ := slices.BinarySearchFunc(entries, entry.Version, func(e historyEntry, version int) int {
+        return e.Version - version
+    })
+    entries = slices.Insert(entries, i, entry)
+    if len(entries) > h.limit {
+        entries = entries[len(entries)-h.limit:]
+    }
+    h.entries[student.ID] = entries
+}
+
+// get returns a copy of the recorded versions of a student, oldest first
+func (h *studentHistory) get(id ID) []historyEntry {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    return append([]historyEntry{}, h.entries[id]...)
+}
+
+// clear forgets every student's history
+func (h *studentHistory) clear() {
+    h.mu.Lock()
+    defer h.mu.Unlock()
+    clear(h.entries)
+}
+
+// GetStudentHistory handles GET /students/{id}/history, listing the most
+// recent versions of a student, oldest first, each with the change that
+// produced it. Soft-deleted students keep their history, but nothing
+// survives a restart since history is never written to the store.
+func (s *Server) GetStudentHistory(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    if _, err := s.getStudent(r.Context(), id, true); err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    s.writeJSON(w, r, map[string]interface{}{"data": s.history.get(id)})
+}
@@ -0,0 +1,108 @@
+package students
+
+import (
+	"cmp"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// ID identifies a student. The zero value means "not assigned yet".
+//
+// With the default numeric format an ID is a positive decimal integer, sent
+// in JSON as a number and stored as an INTEGER, exactly as before IDs became
+// configurable. With the UUID format it is a random UUID, sent as a string.
+// Numeric IDs are short and sort in creation order, which the default
+// listing order and cursor pagination lean on; but they reveal how many
+// students exist and let clients guess each other's IDs. UUIDs reveal
+// nothing, at the price of sorting in no meaningful order. An ID made only
+// of digits is treated as numeric wherever it is encoded, so the two formats
+// can't be confused.
+type ID string
+
+// IDFormat selects how student IDs are generated and parsed
+type IDFormat int
+
+const (
+    // IDNumeric hands out sequential integers starting at 1
+    IDNumeric IDFormat = iota
+    // IDUUID hands out random (version 4) UUIDs
+    IDUUID
+)
+
+// ParseIDFormat parses an ID_FORMAT value: "numeric" (or empty) or "uuid"
+func ParseIDFormat(s string) (IDFormat, error) {
+    switch s {
+    case "", "numeric":
+        return IDNumeric, nil
+    case "uuid":
+        return IDUUID, nil
+    }
+    return 0, fmt.Errorf("unknown ID format %q (want numeric or uuid)", s)
+}
+
+// parse validates a client-supplied ID, returning it in canonical form
+func (f IDFormat) parse(s string) (ID, error) {
+    if f == IDUUID {
+        u, err := uuid.Parse(s)
+        if err != nil {
+            return "", errors.New("must be a UUID")
+        }
+        return ID(u.String()), nil
+    }
+    n, err := strconv.Atoi(s)
+    if err != nil || n < 1 {
+        return "", errors.New("must be a positive integer")
+    }
+    return ID(strconv.Itoa(n)), nil
+}
+
+// numeric returns the integer value of a numeric ID
+func (id ID) numeric() (int, bool) {
+    n, err := strconv.Atoi(string(id))
+    return n, err == nil
+}
+
+// MarshalJSON encodes numeric IDs as numbers and others as strings
+func (id ID) MarshalJSON() ([]byte, error) {
+    if n, ok := id.numeric(); ok {
+        return strconv.AppendInt(nil, int64(n), 10), nil
+    }
+    return json.Marshal(string(id))
+}
+
+// UnmarshalJSON accepts a number or a string. Whether the result is valid
+// for the configured format is checked separately.
+func (id *ID) UnmarshalJSON(b []byte) error {
+    if len(b) > 0 && b[0] == '"' {
+        return json.Unmarshal(b, (*string)(id))
+    }
+    var n json.Number
+    if err := json.Unmarshal(b, &n); err != nil {
+        return err
+    }
+    *id = ID(n)
+    return nil
+}
+
+// Value stores numeric IDs as integers so they keep using SQLite's rowid
+func (id ID) Value() (driver.Value, error) {
+    if n, ok := id.numeric(); ok {
+        return int64(n), nil
+    }
+    return string(id), nil
+}
+
+// compareIDs orders numeric IDs by value and anything else as strings
+func compareIDs(a, b ID) int {
+    an, aOK := a.numeric()
+    bn, bOK := b.numeric()
+    if aOK && bOK {
+        return cmp.Compare(an, bn)
+    }
+    return cmp.Compare(a, b)
+}
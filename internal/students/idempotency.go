@@ -0,0 +1,185 @@
+package students
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyKeyTTL is how long the response to a request carrying an
+// Idempotency-Key is kept for replay
+const idempotencyKeyTTL = 24 * time.Hour
+
+// idempotencyPendingTTL is how long a key stays reserved by a request that
+// never finishes, so it can't block retries, or stay in memory, forever
+const idempotencyPendingTTL = 5 * time.Minute
+
+// maxIdempotencyKeyLength caps the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// replayedHeaders are the response headers stored with an idempotent
+// response. Others, such as X-Request-ID, belong to the retry itself.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// idempotencyCache remembers responses by Idempotency-Key so retried
+// requests get the original result instead of being executed again
+type idempotencyCache struct {
+    mu        sync.Mutex
+    entries   map[string]*idempotencyEntry
+    lastPrune time.Time
+}
+
+type idempotencyEntry struct {
+    fingerprint [sha256.Size]byte // Of the request the key was first used with
+    done        bool              // False while that request is still running
+    status      int
+    header      http.Header
+    body        []byte
+    expires     time.Time // Of the stored response, or of the reservation while pending
+}
+
+func newIdempotencyCache() *idempotencyCache {
+    return &idempotencyCache{entries: make(map[string]*idempotencyEntry), lastPrune: time.Now()}
+}
+
+// reserve returns the entry for key, creating a pending one if there is none
+// so that concurrent requests with the same key don't both run. If it
+// creates one, reservation is non-nil and the caller owns it until passing
+// it to complete or release.
+func (c *idempotencyCache) reserve(key string, fingerprint [sha256.Size]byte) (entry idempotencyEntry, reservation *idempotencyEntry) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    now := time.Now()
+    if now.Sub(c.lastPrune) > time.Minute {
+        for k, e := range c.entries {
+            if now.After(e.expires) {
+                delete(c.entries, k)
+            }
+        }
+        c.lastPrune = now
+    }
+
+    if e, ok := c.entries[key]; ok && now.Before(e.expires) {
+        return *e, nil
+    }
+    reservation = &idempotencyEntry{fingerprint: fingerprint, expires: now.Add(idempotencyPendingTTL)}
+    c.entries[key] = reservation
+    return idempotencyEntry{}, reservation
+}
+
+// complete stores the response for a key reserved by the caller, unless the
+// reservation expired and the key has been taken again since
+func (c *idempotencyCache) complete(key string, reservation *idempotencyEntry, status int, header http.Header, body []byte) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    if c.entries[key] != reservation {
+        return
+    }
+    reservation.done, reservation.status, reservation.header, reservation.body = true, status, header, body
+    reservation.expires = time.Now().Add(idempotencyKeyTTL)
+}
+
+// release forgets a key reserved by the caller so the request can be retried
+func (c *idempotencyCache) release(key string, reservation *idempotencyEntry) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if c.entries[key] == reservation {
+        delete(c.entries, key)
+    }
+}
+
+// idempotent runs handler for a request that may carry an Idempotency-Key
+// header. The first response for a key is stored and replayed to retries
+// with the same method, path and body; reusing the key for a different
+// request gets 422, and retrying while the first attempt is still running
+// gets 409. Server errors aren't stored, so a retry runs the handler again.
+func (s *Server) idempotent(w http.ResponseWriter, r *http.Request, handler http.HandlerFunc) {
+    key := r.Header.Get("Idempotency-Key")
+    if key == "" {
+        handler(w, r)
+        return
+    }
+    if len(key) > maxIdempotencyKeyLength {
+        WriteJSONError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+        return
+    }
+
+    body, ok := readBody(w, r)
+    if !ok {
+        return
+    }
+    r.Body = io.NopCloser(bytes.NewReader(body))
+    fingerprint := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\x00"), body...))
+
+    entry, reservation := s.idempotency.reserve(key, fingerprint)
+    if reservation == nil {
+        switch {
+        case entry.fingerprint != fingerprint:
+            WriteJSONError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+        case !entry.done:
+            WriteJSONError(w, http.StatusConflict, "A request with this Idempotency-Key is still in progress")
+        default:
+            for name, values := range entry.header {
+                w.Header()[name] = values
+            }
+            w.Header().Set("Idempotent-Replayed", "true")
+            w.WriteHeader(entry.status)
+            w.Write(entry.body)
+        }
+        return
+    }
+
+    // Unless a response gets stored the key is freed, even if handler
+    // panics, so the client can retry
+    stored := false
+    defer func() {
+        if !stored {
+            s.idempotency.release(key, reservation)
+        }
+    }()
+
+    rec := &recordingWriter{ResponseWriter: w}
+    handler(rec, r)
+    if rec.status == 0 {
+        rec.status = http.StatusOK
+    }
+    if rec.status >= 500 {
+        return
+    }
+    header := make(http.Header)
+    for _, name := range replayedHeaders {
+        if values := w.Header().Values(name); len(values) > 0 {
+            header[name] = values
+        }
+    }
+    s.idempotency.complete(key, reservation, rec.status, header, rec.body.Bytes())
+    stored = true
+}
+
+// recordingWriter passes a response through while keeping a copy of its
+// status and body
+type recordingWriter struct {
+    http.ResponseWriter
+    status int
+    body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(code int) {
+    if rw.status == 0 {
+        rw.status = code
+    }
+    rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+    if rw.status == 0 {
+        rw.status = http.StatusOK
+    }
+    rw.body.Write(b)
+    return rw.ResponseWriter.Write(b)
+}
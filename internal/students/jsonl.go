@@ -0,0 +1,49 @@
+package students
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+// jsonLinesFlushEvery is how many lines are written between flushes, so
+// consumers see progress without a flush per line
+const jsonLinesFlushEvery = 100
+
+// ExportStudentsJSONLines handles GET /students.jsonl, writing the students
+// matching the list endpoint's filter and sort parameters as JSON Lines: one
+// object per line, without pagination. Lines are encoded straight onto the
+// response and flushed periodically instead of building one big array.
+func (s *Server) ExportStudentsJSONLines(w http.ResponseWriter, r *http.Request) {
+    compare, err := parseSort(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    filter, err := parseFilter(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    studentList = filterStudents(studentList, filter)
+    sortStudents(studentList, compare)
+
+    w.Header().Set("Content-Type", "application/x-ndjson")
+    flusher, _ := w.(http.Flusher)
+    encoder := json.NewEncoder(w) // Encode terminates each value with a newline
+    for i, student := range studentList {
+        if err := encoder.Encode(student); err != nil {
+            slog.Warn("JSON Lines export aborted", "err", err)
+            return
+        }
+        if flusher != nil && (i+1)%jsonLinesFlushEvery == 0 {
+            flusher.Flush()
+        }
+    }
+}
@@ -0,0 +1,239 @@
+package students
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Default page size bounds for GET /students and /students/search; see
+// Server.SetPageSizes
+const (
+    DefaultPageSize    = 20
+    DefaultMaxPageSize = 100
+)
+
+// studentPage is the envelope returned by GET /students. NextCursor is set
+// when the list is sorted by ID and more students follow this page.
+type studentPage struct {
+    Data       []Student `json:"data"`
+    Total      int       `json:"total"`
+    Limit      int       `json:"limit"`
+    Offset     int       `json:"offset"`
+    NextCursor *ID       `json:"next_cursor,omitempty"`
+}
+
+// parsePagination reads the limit and offset query parameters. A limit
+// above the maximum page size is lowered to it rather than rejected; the
+// envelope's limit field tells the client what it got.
+func (s *Server) parsePagination(r *http.Request) (limit, offset int, err error) {
+    limit, offset = s.pageSize, 0
+    q := r.URL.Query()
+    if v := q.Get("limit"); v != "" {
+        limit, err = strconv.Atoi(v)
+        if err != nil || limit < 1 {
+            return 0, 0, errors.New("limit must be a positive number")
+        }
+        limit = min(limit, s.maxPageSize)
+    }
+    if v := q.Get("offset"); v != "" {
+        offset, err = strconv.Atoi(v)
+        if err != nil || offset < 0 {
+            return 0, 0, errors.New("offset must be a non-negative number")
+        }
+    }
+    return limit, offset, nil
+}
+
+// parseCursor reads the optional cursor query parameter, the ID of the last
+// student on the previous page
+func parseCursor(r *http.Request, format IDFormat) (cursor ID, ok bool, err error) {
+    v := r.URL.Query().Get("cursor")
+    if v == "" {
+        return "", false, nil
+    }
+    cursor, err = format.parse(v)
+    if err != nil {
+        return "", false, fmt.Errorf("cursor %v", err)
+    }
+    return cursor, true, nil
+}
+
+// parseIDList parses the comma-separated ids query parameter, dropping
+// duplicates. At most maxIDs may be requested at once.
+func parseIDList(v string, format IDFormat, maxIDs int) ([]ID, error) {
+    var ids []ID
+    seen := make(map[ID]bool)
+    for _, part := range strings.Split(v, ",") {
+        id, err := format.parse(strings.TrimSpace(part))
+        if err != nil {
+            return nil, fmt.Errorf("every id %v", err)
+        }
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+    if len(ids) > maxIDs {
+        return nil, fmt.Errorf("at most %d ids may be requested at once", maxIDs)
+    }
+    return ids, nil
+}
+
+// paginate returns the requested window of an already sorted list
+func paginate(students []Student, limit, offset int) studentPage {
+    page := studentPage{Data: []Student{}, Total: len(students), Limit: limit, Offset: offset}
+    if offset < len(students) {
+        end := min(offset+limit, len(students))
+        page.Data = students[offset:end]
+    }
+    return page
+}
+
+// paginateAfter returns up to limit students following the one with ID
+// cursor in a list sorted by ascending ID. The cursor needn't still exist.
+func paginateAfter(students []Student, limit int, cursor ID) studentPage {
+    offset, _ := slices.BinarySearchFunc(students, cursor, func(s Student, id ID) int {
+        if compareIDs(s.ID, id) <= 0 {
+            return -1
+        }
+        return 1
+    })
+    return paginate(students, limit, offset)
+}
+
+// setNextCursor points page at the student after its last one, if any
+func (page *studentPage) setNextCursor() {
+    if len(page.Data) > 0 && page.Offset+len(page.Data) < page.Total {
+        next := page.Data[len(page.Data)-1].ID
+        page.NextCursor = &next
+    }
+}
+
+// studentSortKeys maps each accepted ?sort= field to its comparison
+var studentSortKeys = map[string]func(a, b Student) int{
+    "id":    func(a, b Student) int { return compareIDs(a.ID, b.ID) },
+    "name":  func(a, b Student) int { return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)) },
+    "age":   func(a, b Student) int { return cmp.Compare(a.Age, b.Age) },
+    "email": func(a, b Student) int { return strings.Compare(strings.ToLower(a.Email), strings.ToLower(b.Email)) },
+}
+
+// sortsByID reports whether the sort query parameter leaves the list in
+// ascending ID order, the only order cursors work with
+func sortsByID(r *http.Request) bool {
+    field := r.URL.Query().Get("sort")
+    return field == "" || field == "id"
+}
+
+// parseSort reads the sort query parameter, e.g. "name" or "-age" for
+// descending order. The default is ascending ID.
+func parseSort(r *http.Request) (func(a, b Student) int, error) {
+    field := r.URL.Query().Get("sort")
+    if field == "" {
+        field = "id"
+    }
+    desc := strings.HasPrefix(field, "-")
+    field = strings.TrimPrefix(field, "-")
+
+    compare, ok := studentSortKeys[field]
+    if !ok {
+        return nil, fmt.Errorf("unknown sort field %q", field)
+    }
+    if desc {
+        return func(a, b Student) int { return compare(b, a) }, nil
+    }
+    return compare, nil
+}
+
+// sortStudents orders students by compare, breaking ties by ascending ID so
+// the result is deterministic regardless of the store's iteration order
+func sortStudents(students []Student, compare func(a, b Student) int) {
+    slices.SortStableFunc(students, func(a, b Student) int {
+        if c := compare(a, b); c != 0 {
+            return c
+        }
+        return compareIDs(a.ID, b.ID)
+    })
+}
+
+// studentFilter holds the optional list filters, combined with AND
+type studentFilter struct {
+    minAge         int
+    maxAge         int
+    name           string // Lower-cased substring to look for in the name
+    includeDeleted bool   // Whether soft-deleted students are listed
+    active         *bool  // Only list students with this Active, if set
+}
+
+// parseFilter reads the min_age, max_age, name, active and include_deleted
+// query parameters
+func parseFilter(r *http.Request) (studentFilter, error) {
+    q := r.URL.Query()
+    minAge, err := intParam(q, "min_age", math.MinInt)
+    if err != nil {
+        return studentFilter{}, err
+    }
+    maxAge, err := intParam(q, "max_age", math.MaxInt)
+    if err != nil {
+        return studentFilter{}, err
+    }
+    var active *bool
+    if v := q.Get("active"); v != "" {
+        b, err := strconv.ParseBool(v)
+        if err != nil {
+            return studentFilter{}, errors.New("active must be true or false")
+        }
+        active = &b
+    }
+    return studentFilter{
+        minAge:         minAge,
+        maxAge:         maxAge,
+        name:           strings.ToLower(q.Get("name")),
+        includeDeleted: q.Get("include_deleted") == "true",
+        active:         active,
+    }, nil
+}
+
+// intParam parses an optional integer query parameter
+func intParam(q url.Values, name string, fallback int) (int, error) {
+    v := q.Get(name)
+    if v == "" {
+        return fallback, nil
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil {
+        return 0, fmt.Errorf("%s must be a number", name)
+    }
+    return n, nil
+}
+
+// matches reports whether s passes every filter
+func (f studentFilter) matches(s Student) bool {
+    return (f.includeDeleted || s.DeletedAt == nil) &&
+        (f.active == nil || s.Active == *f.active) &&
+        s.Age >= f.minAge && s.Age <= f.maxAge &&
+        strings.Contains(strings.ToLower(s.Name), f.name)
+}
+
+// matchesAll reports whether f lets every student through, soft-deleted
+// ones included
+func (f studentFilter) matchesAll() bool {
+    return f.includeDeleted && f.active == nil && f.minAge == math.MinInt && f.maxAge == math.MaxInt && f.name == ""
+}
+
+// filterStudents returns the students that match f
+func filterStudents(students []Student, f studentFilter) []Student {
+    filtered := students[:0]
+    for _, student := range students {
+        if f.matches(student) {
+            filtered = append(filtered, student)
+        }
+    }
+    return filtered
+}
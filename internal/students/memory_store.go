@@ -0,0 +1,199 @@
+package students
+
+import (
+	"context"
+	"sync"
+)
+
+// InMemoryStore is a StudentStore that keeps students in a map. Data does
+// not survive a restart.
+type InMemoryStore struct {
+    mu       sync.RWMutex   // Readers share the lock, writers take it exclusively
+    students map[ID]Student // In-memory data storage
+    ids      idSequence
+}
+
+// NewInMemoryStore returns an empty InMemoryStore handing out IDs in format
+func NewInMemoryStore(format IDFormat) *InMemoryStore {
+    return &InMemoryStore{students: make(map[ID]Student), ids: idSequence{format: format}}
+}
+
+// Create stores s under its own ID or, if that is empty, a newly generated one
+func (st *InMemoryStore) Create(ctx context.Context, s Student) (Student, error) {
+    created, itemErrs, err := st.CreateMany(ctx, []Student{s})
+    if err != nil {
+        return Student{}, err
+    }
+    if itemErrs[0] != nil {
+        return Student{}, itemErrs[0]
+    }
+    return created[0], nil
+}
+
+// CreateMany stores students under a single lock acquisition, skipping
+// those whose ID or email is already taken
+func (st *InMemoryStore) CreateMany(ctx context.Context, students []Student) ([]Student, []error, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, nil, err
+    }
+    st.mu.Lock()
+    defer st.mu.Unlock()
+
+    created := make([]Student, len(students))
+    itemErrs := make([]error, len(students))
+    for i, s := range students {
+        if _, exists := st.students[s.ID]; exists {
+            itemErrs[i] = ErrDuplicateID // No student is stored under the empty ID
+            continue
+        }
+        if st.emailTaken(s.Email, "") {
+            itemErrs[i] = ErrDuplicateEmail
+            continue
+        }
+        if s.ID == "" {
+            s.ID = st.ids.next()
+        } else {
+            st.ids.observe(s.ID)
+        }
+        st.students[s.ID] = s
+        created[i] = s
+    }
+    return created, itemErrs, nil
+}
+
+// emailTaken reports whether a student other than exceptID uses email.
+// This is a linear scan: an email->ID index would make it O(1) but has to
+// be kept in sync on every write, which isn't worth it at the sizes an
+// in-memory store is meant for. Callers must hold mu.
+func (st *InMemoryStore) emailTaken(email string, exceptID ID) bool {
+    for id, student := range st.students {
+        if id != exceptID && student.Email == email {
+            return true
+        }
+    }
+    return false
+}
+
+// GetAll returns every stored student in no particular order. The slice is
+// empty rather than nil when there are none, so it encodes as [].
+func (st *InMemoryStore) GetAll(ctx context.Context) ([]Student, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    st.mu.RLock()
+    defer st.mu.RUnlock()
+
+    studentList := make([]Student, 0, len(st.students))
+    for _, student := range st.students {
+        studentList = append(studentList, student)
+    }
+    return studentList, nil
+}
+
+// GetByID returns the student with the given ID or ErrNotFound
+func (st *InMemoryStore) GetByID(ctx context.Context, id ID) (Student, error) {
+    if err := ctx.Err(); err != nil {
+        return Student{}, err
+    }
+    st.mu.RLock()
+    defer st.mu.RUnlock()
+
+    student, exists := st.students[id]
+    if !exists {
+        return Student{}, ErrNotFound
+    }
+    return student, nil
+}
+
+// Count returns the number of stored students
+func (st *InMemoryStore) Count(ctx context.Context) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    st.mu.RLock()
+    defer st.mu.RUnlock()
+
+    return len(st.students), nil
+}
+
+// Modify applies fn to the student with the given ID under the write lock
+func (st *InMemoryStore) Modify(ctx context.Context, id ID, fn func(s *Student) error) (Student, error) {
+    if err := ctx.Err(); err != nil {
+        return Student{}, err
+    }
+    st.mu.Lock()
+    defer st.mu.Unlock()
+
+    s, exists := st.students[id]
+    if !exists {
+        return Student{}, ErrNotFound
+    }
+    version := s.version
+    if err := fn(&s); err != nil {
+        return Student{}, err
+    }
+    s.ID = id
+    s.version = version + 1
+    if st.emailTaken(s.Email, id) {
+        return Student{}, ErrDuplicateEmail
+    }
+    st.students[id] = s
+    return s, nil
+}
+
+// ModifyMany applies fn to each of the students with the given IDs under a
+// single acquisition of the write lock
+func (st *InMemoryStore) ModifyMany(ctx context.Context, ids []ID, fn func(i int, s *Student) error) ([]Student, []error, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, nil, err
+    }
+    st.mu.Lock()
+    defer st.mu.Unlock()
+
+    modified := make([]Student, len(ids))
+    itemErrs := make([]error, len(ids))
+    for i, id := range ids {
+        s, exists := st.students[id]
+        if !exists {
+            itemErrs[i] = ErrNotFound
+            continue
+        }
+        version := s.version
+        if err := fn(i, &s); err != nil {
+            itemErrs[i] = err
+            continue
+        }
+        s.ID = id
+        s.version = version + 1
+        if st.emailTaken(s.Email, id) {
+            itemErrs[i] = ErrDuplicateEmail
+            continue
+        }
+        st.students[id] = s
+        modified[i] = s
+    }
+    return modified, itemErrs, nil
+}
+
+// DeleteAll removes every student
+func (st *InMemoryStore) DeleteAll(ctx context.Context) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    st.mu.Lock()
+    defer st.mu.Unlock()
+
+    n := len(st.students)
+    clear(st.students)
+    return n, nil
+}
+
+// Ping succeeds unless ctx is done; the map is always available
+func (st *InMemoryStore) Ping(ctx context.Context) error {
+    return ctx.Err()
+}
+
+// IDFormat returns the format the store was created with
+func (st *InMemoryStore) IDFormat() IDFormat {
+    return st.ids.format
+}
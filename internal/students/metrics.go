@@ -0,0 +1,44 @@
+package students
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+    ollamaRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "ollama_requests_total",
+        Help: "Calls to the Ollama generate API, by result (success or failure).",
+    }, []string{"result"})
+
+    // LLM calls run far longer than ordinary requests, hence the wider buckets
+    ollamaRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+        Name:    "ollama_request_duration_seconds",
+        Help:    "Time taken by calls to the Ollama generate API, including retries.",
+        Buckets: []float64{0.25, 0.5, 1, 2.5, 5, 10, 20, 30, 60},
+    })
+
+    summaryCacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "summary_cache_lookups_total",
+        Help: "Lookups of a fresh summary in the summary cache, by result (hit or miss).",
+    }, []string{"result"})
+    summaryCacheHits   = summaryCacheLookups.WithLabelValues("hit")
+    summaryCacheMisses = summaryCacheLookups.WithLabelValues("miss")
+
+    summaryRequestsShared = promauto.NewCounter(prometheus.CounterOpts{
+        Name: "summary_requests_shared_total",
+        Help: "Summary requests answered by joining an Ollama call already in flight for the same student and model instead of making their own.",
+    })
+)
+
+// observeOllamaCall records the outcome of one call to the generate API
+func observeOllamaCall(start time.Time, err error) {
+    result := "success"
+    if err != nil {
+        result = "failure"
+    }
+    ollamaRequestsTotal.WithLabelValues(result).Inc()
+    ollamaRequestDuration.Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,363 @@
+package students
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// OllamaConfig configures an OllamaClient
+type OllamaConfig struct {
+    BaseURL string        // Where Ollama listens, e.g. http://localhost:11434
+    Model   string        // Model used to generate summaries by default
+    // Models lists the other models clients may pick with ?model=
+    Models  []string
+    Timeout time.Duration // Upper bound on a whole generate call
+    // MaxAttempts is how many times a request is tried when Ollama is
+    // unreachable or answers with a 5xx status
+    MaxAttempts int
+    // MaxConcurrent caps the generate calls in flight at once across all
+    // requests; zero means no limit. Calls beyond it wait up to QueueTimeout
+    // for a slot and then fail with ErrOllamaBusy.
+    MaxConcurrent int
+    QueueTimeout  time.Duration
+    // BreakerThreshold is how many consecutive failed calls open the
+    // circuit breaker, failing further calls fast with a *CircuitOpenError
+    // for BreakerCooldown; zero disables it
+    BreakerThreshold int
+    BreakerCooldown  time.Duration
+    // BatchConcurrency is how many summaries POST /students/summaries
+    // generates at once
+    BatchConcurrency int
+    // DisableStreaming makes summaries ask Ollama for a single JSON reply
+    // ("stream": false) rather than a stream of chunks. Streaming summary
+    // endpoints stream regardless.
+    DisableStreaming bool
+    // PromptTemplate renders the summary prompt for a student; nil means
+    // DefaultPromptTemplate. See ParsePromptTemplate.
+    PromptTemplate *template.Template
+}
+
+var (
+    // ErrOllamaUnavailable is returned when the Ollama API can't be reached
+    // or answers with an error status
+    ErrOllamaUnavailable = errors.New("Ollama API unavailable")
+    // ErrOllamaBadResponse is returned when the Ollama API's reply can't be
+    // decoded
+    ErrOllamaBadResponse = errors.New("Malformed response from Ollama API")
+    // ErrOllamaUnexpectedContent is returned when the Ollama API answers
+    // 200 with something other than JSON, such as an HTML error page from a
+    // proxy in front of it
+    ErrOllamaUnexpectedContent = errors.New("Ollama API returned unexpected content")
+    // ErrOllamaBusy is returned when MaxConcurrent calls are already in
+    // flight and none finished within QueueTimeout
+    ErrOllamaBusy = errors.New("Too many concurrent Ollama API calls")
+)
+
+// OllamaError is returned when Ollama reports an error in the middle of a
+// generate stream, e.g. because the model failed to load. It wraps
+// ErrOllamaUnavailable.
+type OllamaError struct {
+    Message string // As reported by Ollama
+}
+
+func (e *OllamaError) Error() string {
+    return "Ollama API error: " + e.Message
+}
+
+func (e *OllamaError) Unwrap() error {
+    return ErrOllamaUnavailable
+}
+
+// ollamaRetryBaseDelay is the backoff before the first retry; it doubles
+// with every further attempt
+const ollamaRetryBaseDelay = 250 * time.Millisecond
+
+// OllamaClient talks to the Ollama generate API
+type OllamaClient struct {
+    cfg            OllamaConfig
+    httpClient     *http.Client
+    promptTemplate *template.Template
+    slots          chan struct{} // One token per call in flight; nil when unlimited
+    breaker        *circuitBreaker
+}
+
+// NewOllamaClient returns a client for the Ollama instance described by cfg
+func NewOllamaClient(cfg OllamaConfig) *OllamaClient {
+    promptTemplate := cfg.PromptTemplate
+    if promptTemplate == nil {
+        promptTemplate = defaultPromptTemplate
+    }
+    c := &OllamaClient{
+        cfg:            cfg,
+        httpClient:     &http.Client{Timeout: cfg.Timeout},
+        promptTemplate: promptTemplate,
+        breaker:        &circuitBreaker{threshold: cfg.BreakerThreshold, cooldown: cfg.BreakerCooldown},
+    }
+    if cfg.MaxConcurrent > 0 {
+        c.slots = make(chan struct{}, cfg.MaxConcurrent)
+    }
+    return c
+}
+
+// acquire waits for a free call slot, giving up with ErrOllamaBusy after
+// QueueTimeout. The returned func releases the slot.
+func (c *OllamaClient) acquire(ctx context.Context) (release func(), err error) {
+    if c.slots == nil {
+        return func() {}, nil
+    }
+    timer := time.NewTimer(c.cfg.QueueTimeout)
+    defer timer.Stop()
+    select {
+    case c.slots <- struct{}{}:
+        return func() { <-c.slots }, nil
+    case <-timer.C:
+        return nil, ErrOllamaBusy
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    }
+}
+
+// resolveModel returns the model a request asked for, or the default one if
+// requested is empty. Only the default and Models may be used, so clients
+// can't make Ollama load arbitrary models.
+func (c *OllamaClient) resolveModel(requested string) (string, error) {
+    if requested == "" || requested == c.cfg.Model || slices.Contains(c.cfg.Models, requested) {
+        return cmp.Or(requested, c.cfg.Model), nil
+    }
+    return "", fmt.Errorf("model must be one of: %s", strings.Join(append([]string{c.cfg.Model}, c.cfg.Models...), ", "))
+}
+
+// callOllamaAPI makes a call to the Ollama API to generate an AI-based summary
+func (c *OllamaClient) callOllamaAPI(ctx context.Context, student Student, opts summaryOptions) (string, error) {
+    prompt, err := c.buildSummaryPrompt(student, opts)
+    if err != nil {
+        return "", err
+    }
+
+    var summary bytes.Buffer
+    err = c.generate(ctx, prompt, opts.model, !c.cfg.DisableStreaming, func(text string) error {
+        summary.WriteString(text)
+        return nil
+    })
+    if err != nil {
+        return "", err
+    }
+
+    slog.Debug("Generated summary", "student_id", student.ID, "summary", summary.String())
+    return summary.String(), nil
+}
+
+// streamOllamaAPI sends prompt to model and calls onChunk with each piece of
+// generated text as it arrives. Cancelling ctx aborts the upstream request.
+func (c *OllamaClient) streamOllamaAPI(ctx context.Context, prompt, model string, onChunk func(text string) error) error {
+    return c.generate(ctx, prompt, model, true, onChunk)
+}
+
+// generate sends prompt to model and hands the generated text to onChunk:
+// piece by piece as it arrives if stream is set, otherwise all at once.
+func (c *OllamaClient) generate(ctx context.Context, prompt, model string, stream bool, onChunk func(text string) error) (err error) {
+    if err := c.breaker.allow(); err != nil {
+        return err
+    }
+    defer func() { c.breaker.record(err) }()
+    // Whatever broke once the caller gave up was most likely caused by that,
+    // so report it as the caller's doing rather than Ollama's
+    defer func() {
+        if err != nil && ctx.Err() != nil {
+            err = ctx.Err()
+        }
+    }()
+
+    release, err := c.acquire(ctx)
+    if err != nil {
+        return err
+    }
+    defer release()
+
+    start := time.Now()
+    defer func() { observeOllamaCall(start, err) }()
+
+    // Prepare the request payload
+    requestPayload := map[string]interface{}{
+        "model":  model,
+        "prompt": prompt,
+        "stream": stream,
+    }
+
+    requestBody, err := json.Marshal(requestPayload)
+    if err != nil {
+        return fmt.Errorf("Failed to encode request payload: %v", err)
+    }
+
+    resp, err := c.postGenerate(ctx, requestBody)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("%w: non-200 status %d", ErrOllamaUnavailable, resp.StatusCode)
+    }
+
+    // Ollama streams NDJSON. Anything else is most likely an error page from
+    // something between us and Ollama, which is worth telling apart from a
+    // broken stream.
+    body := &headRecorder{r: resp.Body}
+    contentType := resp.Header.Get("Content-Type")
+    if contentType != "" && !strings.Contains(contentType, "json") {
+        body.Read(make([]byte, headRecorderSize))
+        return unexpectedContent(contentType, body.head)
+    }
+    decoder := json.NewDecoder(body)
+
+    if !stream {
+        var reply struct {
+            Response string `json:"response"`
+            Error    string `json:"error"`
+        }
+        if err := decoder.Decode(&reply); err != nil {
+            return unexpectedContent(contentType, body.head)
+        }
+        if reply.Error != "" {
+            return &OllamaError{Message: reply.Error}
+        }
+        return onChunk(reply.Response)
+    }
+
+    for first := true; decoder.More(); first = false {
+        var chunk map[string]interface{}
+        if err := decoder.Decode(&chunk); err != nil {
+            if first {
+                return unexpectedContent(contentType, body.head)
+            }
+            return fmt.Errorf("%w: failed to decode chunk: %w", ErrOllamaBadResponse, err)
+        }
+
+        // Ollama reports failures after a 200 as a chunk with an error key;
+        // carrying on would pass off a truncated summary as complete
+        if msg, ok := chunk["error"].(string); ok {
+            return &OllamaError{Message: msg}
+        }
+
+        // Hand over the response text
+        if response, ok := chunk["response"].(string); ok && response != "" {
+            if err := onChunk(response); err != nil {
+                return err
+            }
+        }
+
+        // Check for the "done" flag to stop reading
+        if done, ok := chunk["done"].(bool); ok && done {
+            break
+        }
+    }
+    return nil
+}
+
+// unexpectedContent logs the start of a response body that isn't JSON and
+// returns ErrOllamaUnexpectedContent. The body stays out of the error, which
+// is passed on to clients.
+func unexpectedContent(contentType string, head []byte) error {
+    slog.Error("Ollama API returned unexpected content", "content_type", contentType, "body_start", string(head))
+    return ErrOllamaUnexpectedContent
+}
+
+// headRecorderSize is how much of a response headRecorder keeps
+const headRecorderSize = 512
+
+// headRecorder passes reads through to r, keeping the first bytes read for
+// logging
+type headRecorder struct {
+    r    io.Reader
+    head []byte
+}
+
+func (h *headRecorder) Read(p []byte) (int, error) {
+    n, err := h.r.Read(p)
+    if keep := min(n, headRecorderSize-len(h.head)); keep > 0 {
+        h.head = append(h.head, p[:keep]...)
+    }
+    return n, err
+}
+
+// Ping checks that the Ollama API is reachable
+func (c *OllamaClient) Ping(ctx context.Context) error {
+    req, err := http.NewRequestWithContext(ctx, "GET", c.cfg.BaseURL+"/api/tags", nil)
+    if err != nil {
+        return fmt.Errorf("Failed to create request: %v", err)
+    }
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return fmt.Errorf("Failed to reach Ollama API: %w", err)
+    }
+    resp.Body.Close()
+    if resp.StatusCode != http.StatusOK {
+        return fmt.Errorf("Ollama API returned non-200 status: %d", resp.StatusCode)
+    }
+    return nil
+}
+
+// postGenerate sends a generate request, retrying with exponential backoff
+// and jitter while Ollama is unreachable or failing with a 5xx status. 4xx
+// responses are returned to the caller as-is since repeating them won't help.
+func (c *OllamaClient) postGenerate(ctx context.Context, body []byte) (*http.Response, error) {
+    var lastErr error
+    for attempt := 1; attempt <= c.cfg.MaxAttempts; attempt++ {
+        if attempt > 1 {
+            delay := ollamaRetryBaseDelay << (attempt - 2)
+            delay += time.Duration(rand.Int63n(int64(delay) / 2))
+            slog.Warn("Retrying Ollama API", "delay", delay, "attempt", attempt, "max_attempts", c.cfg.MaxAttempts, "err", lastErr)
+            select {
+            case <-time.After(delay):
+            case <-ctx.Done():
+                return nil, ctx.Err()
+            }
+        }
+
+        req, err := http.NewRequestWithContext(ctx, "POST", c.cfg.BaseURL+"/api/generate", bytes.NewReader(body))
+        if err != nil {
+            return nil, fmt.Errorf("Failed to create request: %v", err)
+        }
+        req.Header.Set("Content-Type", "application/json")
+
+        resp, err := c.httpClient.Do(req)
+        if err != nil {
+            // A cancelled caller is not Ollama's fault, and neither it nor a
+            // call that already used up the whole timeout is worth repeating
+            if ctx.Err() != nil {
+                return nil, ctx.Err()
+            }
+            lastErr = fmt.Errorf("%w: %w", ErrOllamaUnavailable, err)
+            if isTimeout(err) {
+                return nil, lastErr
+            }
+            continue
+        }
+        if resp.StatusCode >= 500 {
+            resp.Body.Close()
+            lastErr = fmt.Errorf("%w: non-200 status %d", ErrOllamaUnavailable, resp.StatusCode)
+            continue
+        }
+        return resp, nil
+    }
+    return nil, lastErr
+}
+
+// isTimeout reports whether err was caused by a deadline or client timeout
+func isTimeout(err error) bool {
+    var netErr net.Error
+    return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
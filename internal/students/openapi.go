@@ -0,0 +1,18 @@
+package students
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPISpec is the hand-maintained OpenAPI 3 description of the API. Keep
+// it in sync when adding or changing routes.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec handles GET /openapi.json
+func (s *Server) OpenAPISpec(w http.ResponseWriter, r *http.Request) {
+    w.Header().Set("Content-Type", "application/json")
+    w.Write(openAPISpec)
+}
@@ -0,0 +1,105 @@
+package students
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// DefaultPromptTemplate is the summary prompt used unless one is configured.
+// Templates are rendered with the Student, so {{.ID}}, {{.Name}}, {{.Age}}
+// and {{.Email}} are available.
+const DefaultPromptTemplate = "Summarize the following information about the student with ID {{.ID}} in a paragraph. " +
+    "The student's name is {{.Name}}, they are {{.Age}} years old, and their email is {{.Email}}."
+
+// ParsePromptTemplate parses a summary prompt template. The template is
+// rendered once against a sample student so that references to unknown
+// fields are reported here rather than on the first summary request.
+func ParsePromptTemplate(text string) (*template.Template, error) {
+    tmpl, err := template.New("prompt").Parse(text)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to parse prompt template: %v", err)
+    }
+    if err := tmpl.Execute(&strings.Builder{}, Student{}); err != nil {
+        return nil, fmt.Errorf("Failed to render prompt template: %v", err)
+    }
+    return tmpl, nil
+}
+
+// defaultPromptTemplate is DefaultPromptTemplate, parsed
+var defaultPromptTemplate = template.Must(ParsePromptTemplate(DefaultPromptTemplate))
+
+// Bounds on ?max_words=
+const (
+    minSummaryWords = 10
+    maxSummaryWords = 500
+)
+
+// summaryOptions are what a summary request may ask for on top of the
+// student. Summaries generated with different options are cached apart.
+type summaryOptions struct {
+    model    string
+    maxWords int    // Zero means no limit
+    style    string // "short", "detailed" or empty for the template's own
+}
+
+// parseSummaryOptions reads ?model=, ?max_words= and ?style= from a summary
+// request
+func (c *OllamaClient) parseSummaryOptions(q url.Values) (summaryOptions, error) {
+    model, err := c.resolveModel(q.Get("model"))
+    if err != nil {
+        return summaryOptions{}, err
+    }
+    opts := summaryOptions{model: model}
+
+    if v := q.Get("max_words"); v != "" {
+        n, err := strconv.Atoi(v)
+        if err != nil || n < minSummaryWords || n > maxSummaryWords {
+            return summaryOptions{}, fmt.Errorf("max_words must be a number between %d and %d", minSummaryWords, maxSummaryWords)
+        }
+        opts.maxWords = n
+    }
+    switch style := q.Get("style"); style {
+    case "", "short", "detailed":
+        opts.style = style
+    default:
+        return summaryOptions{}, fmt.Errorf("style must be short or detailed")
+    }
+    return opts, nil
+}
+
+// key identifies opts among concurrent summary requests
+func (o summaryOptions) key() string {
+    return o.model + "\x00" + strconv.Itoa(o.maxWords) + "\x00" + o.style
+}
+
+// instructions returns the sentences appended to the prompt to get a summary
+// of the requested length
+func (o summaryOptions) instructions() string {
+    var parts []string
+    switch o.style {
+    case "short":
+        parts = append(parts, "Keep it short: two or three sentences at most.")
+    case "detailed":
+        parts = append(parts, "Make it detailed, covering everything given above.")
+    }
+    if o.maxWords > 0 {
+        parts = append(parts, fmt.Sprintf("Keep it under %d words.", o.maxWords))
+    }
+    return strings.Join(parts, " ")
+}
+
+// buildSummaryPrompt returns the prompt sent to Ollama for a student, with
+// any length constraints from opts added after the template's text
+func (c *OllamaClient) buildSummaryPrompt(student Student, opts summaryOptions) (string, error) {
+    var prompt strings.Builder
+    if err := c.promptTemplate.Execute(&prompt, student); err != nil {
+        return "", fmt.Errorf("Failed to render prompt: %v", err)
+    }
+    if extra := opts.instructions(); extra != "" {
+        return strings.TrimRight(prompt.String(), " \n") + " " + extra, nil
+    }
+    return prompt.String(), nil
+}
@@ -0,0 +1,132 @@
+package students
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed student.schema.json
+var studentSchemaJSON []byte
+
+// schemaCompiler holds the parsed student.schema.json
+var schemaCompiler = newSchemaCompiler()
+
+// Compiled schemas for each kind of request body
+var (
+    studentSchema      = schemaCompiler.MustCompile("student.schema.json#/$defs/student")
+    newStudentSchema   = schemaCompiler.MustCompile("student.schema.json#/$defs/newStudent")
+    newStudentsSchema  = schemaCompiler.MustCompile("student.schema.json#/$defs/newStudents")
+    studentPatchSchema = schemaCompiler.MustCompile("student.schema.json#/$defs/studentPatch")
+    mergePatchSchema   = schemaCompiler.MustCompile("student.schema.json#/$defs/studentMergePatch")
+    patchItemSchema    = schemaCompiler.MustCompile("student.schema.json#/$defs/studentPatchItem")
+    patchesSchema      = schemaCompiler.MustCompile("student.schema.json#/$defs/studentPatches")
+    emailChangeSchema  = schemaCompiler.MustCompile("student.schema.json#/$defs/emailChange")
+    emailConfirmSchema = schemaCompiler.MustCompile("student.schema.json#/$defs/emailChangeConfirmation")
+    summaryBatchSchema = schemaCompiler.MustCompile("student.schema.json#/$defs/summaryBatch")
+)
+
+// newSchemaCompiler returns a compiler loaded with student.schema.json. The
+// schema is embedded, so failing to load it is a programming error.
+func newSchemaCompiler() *jsonschema.Compiler {
+    doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(studentSchemaJSON))
+    if err != nil {
+        panic(fmt.Sprintf("Failed to parse student.schema.json: %v", err))
+    }
+    c := jsonschema.NewCompiler()
+    c.AssertFormat()
+    if err := c.AddResource("student.schema.json", doc); err != nil {
+        panic(fmt.Sprintf("Failed to load student.schema.json: %v", err))
+    }
+    return c
+}
+
+// schemaPrinter renders the library's messages for violations that
+// describeSchemaViolation has no wording of its own for
+var schemaPrinter = message.NewPrinter(language.English)
+
+// schemaFieldErrors flattens a schema validation failure into one entry
+// per violation, sorted by field. Fields inside arrays are named by index,
+// e.g. "2.email".
+func schemaFieldErrors(err error) ValidationErrors {
+    var ve *jsonschema.ValidationError
+    if !errors.As(err, &ve) {
+        return ValidationErrors{{Message: err.Error()}}
+    }
+    var fieldErrs ValidationErrors
+    collectSchemaViolations(ve, &fieldErrs)
+    slices.SortStableFunc(fieldErrs, func(a, b ValidationError) int { return strings.Compare(a.Field, b.Field) })
+    return fieldErrs
+}
+
+// collectSchemaViolations appends the leaf violations under ve to fieldErrs
+func collectSchemaViolations(ve *jsonschema.ValidationError, fieldErrs *ValidationErrors) {
+    if len(ve.Causes) > 0 {
+        for _, cause := range ve.Causes {
+            collectSchemaViolations(cause, fieldErrs)
+        }
+        return
+    }
+
+    field := strings.Join(ve.InstanceLocation, ".")
+    switch k := ve.ErrorKind.(type) {
+    case *kind.Required:
+        for _, missing := range k.Missing {
+            *fieldErrs = append(*fieldErrs, ValidationError{Field: joinField(field, missing), Message: "is required"})
+        }
+    case *kind.AdditionalProperties:
+        for _, unknown := range k.Properties {
+            *fieldErrs = append(*fieldErrs, ValidationError{Field: joinField(field, unknown), Message: "unknown field"})
+        }
+    default:
+        *fieldErrs = append(*fieldErrs, ValidationError{Field: field, Message: describeSchemaViolation(ve.ErrorKind)})
+    }
+}
+
+// joinField appends a property name to a dotted field path
+func joinField(parent, name string) string {
+    if parent == "" {
+        return name
+    }
+    return parent + "." + name
+}
+
+// describeSchemaViolation words a single violation for API clients
+func describeSchemaViolation(k jsonschema.ErrorKind) string {
+    switch k := k.(type) {
+    case *kind.Type:
+        return "must be of type " + strings.Join(k.Want, " or ")
+    case *kind.MinLength:
+        if k.Want == 1 {
+            return "must not be empty"
+        }
+        return fmt.Sprintf("must be at least %d characters long", k.Want)
+    case *kind.Pattern:
+        if k.Want == `\S` {
+            return "must not be blank"
+        }
+        return "must match the pattern " + k.Want
+    case *kind.Minimum:
+        return "must be at least " + k.Want.RatString()
+    case *kind.Maximum:
+        return "must be at most " + k.Want.RatString()
+    case *kind.Format:
+        return "must be a valid " + k.Want
+    case *kind.MinItems:
+        if k.Want == 1 {
+            return "must not be empty"
+        }
+        return fmt.Sprintf("must have at least %d items", k.Want)
+    case *kind.MaxItems:
+        return fmt.Sprintf("must have at most %d items", k.Want)
+    }
+    return k.LocalizedString(schemaPrinter)
+}
@@ -0,0 +1,160 @@
+package students
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Scores given to a search match, best first
+const (
+    scorePrefix     = 3 // The field starts with the term
+    scoreWordPrefix = 2 // A word inside the field starts with the term
+    scoreSubstring  = 1 // The term appears somewhere else in the field
+)
+
+// searchResult is one ranked hit returned by GET /students/search
+type searchResult struct {
+    Student Student `json:"student"`
+    Score   int     `json:"score"`
+}
+
+// SearchStudents handles GET /students/search?q=term, matching the term
+// case-insensitively against names and emails. Results are ranked by score,
+// so prefix matches come before matches in the middle of a word.
+func (s *Server) SearchStudents(w http.ResponseWriter, r *http.Request) {
+    term := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("q")))
+    if term == "" {
+        WriteJSONError(w, http.StatusBadRequest, "q is required")
+        return
+    }
+    limit, offset, err := s.parsePagination(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    includeDeleted := r.URL.Query().Get("include_deleted") == "true"
+
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    results := []searchResult{}
+    for _, student := range studentList {
+        if student.DeletedAt != nil && !includeDeleted {
+            continue
+        }
+        score := max(matchScore(student.Name, term), matchScore(student.Email, term))
+        if score > 0 {
+            results = append(results, searchResult{Student: student, Score: score})
+        }
+    }
+    slices.SortFunc(results, func(a, b searchResult) int {
+        if c := cmp.Compare(b.Score, a.Score); c != 0 {
+            return c
+        }
+        return compareIDs(a.Student.ID, b.Student.ID)
+    })
+
+    total := len(results)
+    if offset < total {
+        results = results[offset:min(offset+limit, total)]
+    } else {
+        results = []searchResult{}
+    }
+    s.writeJSON(w, r, map[string]interface{}{
+        "data":   results,
+        "total":  total,
+        "limit":  limit,
+        "offset": offset,
+    })
+}
+
+// matchScore rates how well field matches the lower-cased term, returning 0
+// when it doesn't contain it at all
+func matchScore(field, term string) int {
+    field = strings.ToLower(field)
+    i := strings.Index(field, term)
+    switch {
+    case i < 0:
+        return 0
+    case i == 0:
+        return scorePrefix
+    }
+    // A later occurrence may still start a word even if the first doesn't
+    for ; i >= 0; i = nextIndex(field, term, i) {
+        if isWordBoundary(field[i-1]) {
+            return scoreWordPrefix
+        }
+    }
+    return scoreSubstring
+}
+
+// nextIndex returns the index of the next occurrence of term after the one
+// at i, or -1
+func nextIndex(field, term string, i int) int {
+    j := strings.Index(field[i+1:], term)
+    if j < 0 {
+        return -1
+    }
+    return i + 1 + j
+}
+
+// isWordBoundary reports whether c separates words in a name or email
+func isWordBoundary(c byte) bool {
+    return strings.IndexByte(" .-_@+'", c) >= 0
+}
+
+// NearestAgeStudent handles GET /students/{id}/nearest-age, returning the
+// student whose age is closest to that of student {id}, the lowest ID
+// winning ties. Soft-deleted students are left out on both sides; 404 is
+// returned when there is nobody else to compare with.
+func (s *Server) NearestAgeStudent(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+
+    subject, err := s.getStudent(r.Context(), id, false)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    var nearest *Student
+    for i, student := range studentList {
+        if student.ID == subject.ID || student.DeletedAt != nil {
+            continue
+        }
+        if nearest == nil {
+            nearest = &studentList[i]
+            continue
+        }
+        c := cmp.Compare(ageGap(student, subject), ageGap(*nearest, subject))
+        if c < 0 || c == 0 && compareIDs(student.ID, nearest.ID) < 0 {
+            nearest = &studentList[i]
+        }
+    }
+    if nearest == nil {
+        WriteJSONError(w, http.StatusNotFound, "No other student to compare with")
+        return
+    }
+    s.writeJSON(w, r, nearest)
+}
+
+// ageGap returns how many years apart the ages of a and b are
+func ageGap(a, b Student) int {
+    if a.Age > b.Age {
+        return a.Age - b.Age
+    }
+    return b.Age - a.Age
+}
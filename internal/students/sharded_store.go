@@ -0,0 +1,299 @@
+package students
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+)
+
+// DefaultShards is the number of shards main gives a ShardedStore unless
+// STORE_SHARDS says otherwise
+const DefaultShards = 16
+
+// ShardedStore is an in-memory StudentStore that spreads students over
+// several maps, each behind its own lock, so writes to different students
+// don't queue up behind one global lock the way they do on InMemoryStore.
+// Emails must be unique across shards, so they are tracked in a separate
+// index whose lock is only held for an O(1) lookup. Operations on many
+// students at once lock every shard. Data does not survive a restart.
+//
+// Locks are always taken in shard order, then the email index's, so they
+// can't deadlock.
+type ShardedStore struct {
+    shards []storeShard
+    ids    idSequence
+
+    emailMu sync.Mutex
+    emails  map[string]ID // Email -> ID of the student using it
+}
+
+// storeShard holds the students whose IDs map to it
+type storeShard struct {
+    mu       sync.RWMutex
+    students map[ID]Student
+}
+
+// NewShardedStore returns an empty ShardedStore with the given number of
+// shards, which must be at least 1, handing out IDs in format
+func NewShardedStore(shards int, format IDFormat) *ShardedStore {
+    st := &ShardedStore{
+        shards: make([]storeShard, shards),
+        ids:    idSequence{format: format},
+        emails: make(map[string]ID),
+    }
+    for i := range st.shards {
+        st.shards[i].students = make(map[ID]Student)
+    }
+    return st
+}
+
+// shard returns the shard holding id: id % N for numeric IDs, a hash of the
+// ID for UUIDs
+func (st *ShardedStore) shard(id ID) *storeShard {
+    if n, ok := id.numeric(); ok {
+        return &st.shards[uint(n)%uint(len(st.shards))]
+    }
+    h := fnv.New32a()
+    h.Write([]byte(id))
+    return &st.shards[h.Sum32()%uint32(len(st.shards))]
+}
+
+// lockAll write-locks every shard, in order
+func (st *ShardedStore) lockAll() {
+    for i := range st.shards {
+        st.shards[i].mu.Lock()
+    }
+}
+
+func (st *ShardedStore) unlockAll() {
+    for i := range st.shards {
+        st.shards[i].mu.Unlock()
+    }
+}
+
+// rlockAll read-locks every shard, in order, for a consistent view of the
+// whole store
+func (st *ShardedStore) rlockAll() {
+    for i := range st.shards {
+        st.shards[i].mu.RLock()
+    }
+}
+
+func (st *ShardedStore) runlockAll() {
+    for i := range st.shards {
+        st.shards[i].mu.RUnlock()
+    }
+}
+
+// claimEmail moves the student id from oldEmail to newEmail in the email
+// index, failing with ErrDuplicateEmail if another student has newEmail.
+// oldEmail is empty for a new student. Callers must hold the lock of id's
+// shard.
+func (st *ShardedStore) claimEmail(id ID, oldEmail, newEmail string) error {
+    st.emailMu.Lock()
+    defer st.emailMu.Unlock()
+
+    if owner, taken := st.emails[newEmail]; taken && owner != id {
+        return ErrDuplicateEmail
+    }
+    if oldEmail != newEmail {
+        delete(st.emails, oldEmail)
+    }
+    st.emails[newEmail] = id
+    return nil
+}
+
+// insert stores s in sh, which must be its shard and locked by the caller
+func (st *ShardedStore) insert(sh *storeShard, s Student, generated bool) error {
+    if _, exists := sh.students[s.ID]; exists {
+        return ErrDuplicateID
+    }
+    if err := st.claimEmail(s.ID, "", s.Email); err != nil {
+        return err
+    }
+    if !generated {
+        st.ids.observe(s.ID)
+    }
+    sh.students[s.ID] = s
+    return nil
+}
+
+// Create stores s under its own ID or, if that is empty, a newly generated
+// one, locking only the shard it lands in
+func (st *ShardedStore) Create(ctx context.Context, s Student) (Student, error) {
+    if err := ctx.Err(); err != nil {
+        return Student{}, err
+    }
+    generated := s.ID == ""
+    for {
+        if generated {
+            s.ID = st.ids.next()
+        }
+        sh := st.shard(s.ID)
+        sh.mu.Lock()
+        err := st.insert(sh, s, generated)
+        sh.mu.Unlock()
+        if err == ErrDuplicateID && generated {
+            continue // A client took the ID between next and the lock
+        }
+        if err != nil {
+            return Student{}, err
+        }
+        return s, nil
+    }
+}
+
+// CreateMany stores students with every shard locked, skipping those whose
+// ID or email is already taken
+func (st *ShardedStore) CreateMany(ctx context.Context, students []Student) ([]Student, []error, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, nil, err
+    }
+    st.lockAll()
+    defer st.unlockAll()
+
+    created := make([]Student, len(students))
+    itemErrs := make([]error, len(students))
+    for i, s := range students {
+        generated := s.ID == ""
+        if generated {
+            s.ID = st.ids.next()
+        }
+        if err := st.insert(st.shard(s.ID), s, generated); err != nil {
+            itemErrs[i] = err
+            continue
+        }
+        created[i] = s
+    }
+    return created, itemErrs, nil
+}
+
+// GetAll returns every stored student in no particular order. The slice is
+// empty rather than nil when there are none, so it encodes as [].
+func (st *ShardedStore) GetAll(ctx context.Context) ([]Student, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, err
+    }
+    st.rlockAll()
+    defer st.runlockAll()
+
+    studentList := make([]Student, 0)
+    for i := range st.shards {
+        for _, student := range st.shards[i].students {
+            studentList = append(studentList, student)
+        }
+    }
+    return studentList, nil
+}
+
+// GetByID returns the student with the given ID or ErrNotFound
+func (st *ShardedStore) GetByID(ctx context.Context, id ID) (Student, error) {
+    if err := ctx.Err(); err != nil {
+        return Student{}, err
+    }
+    sh := st.shard(id)
+    sh.mu.RLock()
+    defer sh.mu.RUnlock()
+
+    student, exists := sh.students[id]
+    if !exists {
+        return Student{}, ErrNotFound
+    }
+    return student, nil
+}
+
+// Count returns the number of stored students
+func (st *ShardedStore) Count(ctx context.Context) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    st.rlockAll()
+    defer st.runlockAll()
+
+    n := 0
+    for i := range st.shards {
+        n += len(st.shards[i].students)
+    }
+    return n, nil
+}
+
+// Modify applies fn to the student with the given ID under its shard's
+// write lock
+func (st *ShardedStore) Modify(ctx context.Context, id ID, fn func(s *Student) error) (Student, error) {
+    if err := ctx.Err(); err != nil {
+        return Student{}, err
+    }
+    sh := st.shard(id)
+    sh.mu.Lock()
+    defer sh.mu.Unlock()
+
+    return st.modify(sh, id, fn)
+}
+
+// modify is Modify for callers already holding the write lock of sh, the
+// shard of id
+func (st *ShardedStore) modify(sh *storeShard, id ID, fn func(s *Student) error) (Student, error) {
+    old, exists := sh.students[id]
+    if !exists {
+        return Student{}, ErrNotFound
+    }
+    s := old
+    if err := fn(&s); err != nil {
+        return Student{}, err
+    }
+    s.ID = id
+    s.version = old.version + 1
+    if err := st.claimEmail(id, old.Email, s.Email); err != nil {
+        return Student{}, err
+    }
+    sh.students[id] = s
+    return s, nil
+}
+
+// ModifyMany applies fn to each of the students with the given IDs with
+// every shard locked
+func (st *ShardedStore) ModifyMany(ctx context.Context, ids []ID, fn func(i int, s *Student) error) ([]Student, []error, error) {
+    if err := ctx.Err(); err != nil {
+        return nil, nil, err
+    }
+    st.lockAll()
+    defer st.unlockAll()
+
+    modified := make([]Student, len(ids))
+    itemErrs := make([]error, len(ids))
+    for i, id := range ids {
+        modified[i], itemErrs[i] = st.modify(st.shard(id), id, func(s *Student) error {
+            return fn(i, s)
+        })
+    }
+    return modified, itemErrs, nil
+}
+
+// DeleteAll removes every student
+func (st *ShardedStore) DeleteAll(ctx context.Context) (int, error) {
+    if err := ctx.Err(); err != nil {
+        return 0, err
+    }
+    st.lockAll()
+    defer st.unlockAll()
+
+    n := 0
+    for i := range st.shards {
+        n += len(st.shards[i].students)
+        clear(st.shards[i].students)
+    }
+    st.emailMu.Lock()
+    clear(st.emails)
+    st.emailMu.Unlock()
+    return n, nil
+}
+
+// Ping succeeds unless ctx is done; the maps are always available
+func (st *ShardedStore) Ping(ctx context.Context) error {
+    return ctx.Err()
+}
+
+// IDFormat returns the format the store was created with
+func (st *ShardedStore) IDFormat() IDFormat {
+    return st.ids.format
+}
@@ -0,0 +1,459 @@
+package students
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+// sqliteSchema creates the students table. %s is the type of the id column,
+// from sqliteIDTypes. store_meta holds values that must outlive the rows
+// they were derived from, such as the highest numeric ID handed out.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS students (
+    id    %s PRIMARY KEY,
+    name  TEXT    NOT NULL,
+    age   INTEGER NOT NULL,
+    email TEXT    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS students_email ON students (email);
+CREATE TABLE IF NOT EXISTS store_meta (
+    key   TEXT    PRIMARY KEY,
+    value INTEGER NOT NULL
+)`
+
+// sqliteIDTypes is the id column type used for each ID format. Numeric IDs
+// alias SQLite's rowid; UUIDs are kept as text.
+var sqliteIDTypes = map[IDFormat]string{
+    IDNumeric: "INTEGER",
+    IDUUID:    "TEXT",
+}
+
+// sqliteAddedColumns are columns introduced after the original schema. They
+// are added to existing databases on startup, so each needs a default that
+// is valid for rows written by older versions.
+var sqliteAddedColumns = []struct{ name, definition string }{
+    {"created_at", "DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'"},
+    {"updated_at", "DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'"},
+    {"deleted_at", "DATETIME"},
+    {"pending_email", "TEXT NOT NULL DEFAULT ''"},
+    {"email_token_hash", "TEXT NOT NULL DEFAULT ''"},
+    {"email_token_expires", "DATETIME NOT NULL DEFAULT '0001-01-01 00:00:00+00:00'"},
+    {"active", "BOOLEAN NOT NULL DEFAULT 1"},
+    {"version", "INTEGER NOT NULL DEFAULT 0"},
+}
+
+// studentColumns is the column list matching scanStudent
+const studentColumns = `id, name, age, email, created_at, updated_at, deleted_at, pending_email, email_token_hash, email_token_expires, active, version`
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+// scanStudent reads a row selected with studentColumns
+func scanStudent(row rowScanner) (Student, error) {
+    var s Student
+    var deletedAt sql.NullTime
+    err := row.Scan((*string)(&s.ID), &s.Name, &s.Age, &s.Email, &s.CreatedAt, &s.UpdatedAt, &deletedAt,
+        &s.PendingEmail, &s.emailTokenHash, &s.emailTokenExpires, &s.Active, &s.version)
+    if deletedAt.Valid {
+        s.DeletedAt = &deletedAt.Time
+    }
+    return s, err
+}
+
+// SQLiteStore is a StudentStore backed by a SQLite database file
+type SQLiteStore struct {
+    db  *sql.DB
+    ids idSequence
+}
+
+// NewSQLiteStore opens the database at path and creates the schema if
+// needed. The format of a database's IDs can't change once it has been
+// created, so opening it with a different format fails.
+func NewSQLiteStore(path string, format IDFormat) (*SQLiteStore, error) {
+    db, err := sql.Open("sqlite", path)
+    if err != nil {
+        return nil, fmt.Errorf("Failed to open database: %v", err)
+    }
+    // SQLite allows a single writer; funnelling everything through one
+    // connection avoids SQLITE_BUSY errors under concurrent requests.
+    db.SetMaxOpenConns(1)
+
+    if _, err := db.Exec(fmt.Sprintf(sqliteSchema, sqliteIDTypes[format])); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("Failed to create schema: %v", err)
+    }
+    var idType string
+    if err := db.QueryRow(`SELECT type FROM pragma_table_info('students') WHERE name = 'id'`).Scan(&idType); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("Failed to read schema: %v", err)
+    }
+    if !strings.EqualFold(idType, sqliteIDTypes[format]) {
+        db.Close()
+        return nil, fmt.Errorf("Database %s has %s IDs, which don't match the configured ID format", path, idType)
+    }
+    if err := migrateSQLite(db); err != nil {
+        db.Close()
+        return nil, fmt.Errorf("Failed to migrate schema: %v", err)
+    }
+
+    st := &SQLiteStore{db: db, ids: idSequence{format: format}}
+    if format == IDNumeric {
+        // Students removed for good no longer show in MAX(id), so the
+        // highest ID recorded before removing them counts too
+        var maxID int
+        err := db.QueryRow(`SELECT MAX(
+            COALESCE((SELECT MAX(id) FROM students), 0),
+            COALESCE((SELECT value FROM store_meta WHERE key = 'last_id'), 0))`).Scan(&maxID)
+        if err != nil {
+            db.Close()
+            return nil, fmt.Errorf("Failed to read highest student ID: %v", err)
+        }
+        st.ids.seed(maxID)
+    }
+    return st, nil
+}
+
+// migrateSQLite adds any of sqliteAddedColumns the students table lacks and
+// brings emails stored by older versions into normalized form
+func migrateSQLite(db *sql.DB) error {
+    rows, err := db.Query(`SELECT name FROM pragma_table_info('students')`)
+    if err != nil {
+        return err
+    }
+    existing := make(map[string]bool)
+    for rows.Next() {
+        var name string
+        if err := rows.Scan(&name); err != nil {
+            rows.Close()
+            return err
+        }
+        existing[name] = true
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    for _, col := range sqliteAddedColumns {
+        if existing[col.name] {
+            continue
+        }
+        if _, err := db.Exec(`ALTER TABLE students ADD COLUMN ` + col.name + ` ` + col.definition); err != nil {
+            return err
+        }
+    }
+    return normalizeSQLiteEmails(db)
+}
+
+// normalizeSQLiteEmails rewrites emails that aren't trimmed and lower-cased,
+// so checkEmailFree's exact comparison catches duplicates differing by case.
+// It's done in Go because SQLite's lower() only folds ASCII.
+func normalizeSQLiteEmails(db *sql.DB) error {
+    tx, err := db.Begin()
+    if err != nil {
+        return err
+    }
+    defer tx.Rollback()
+
+    rows, err := tx.Query(`SELECT id, email FROM students`)
+    if err != nil {
+        return err
+    }
+    changed := make(map[string]string)
+    for rows.Next() {
+        var id, email string
+        if err := rows.Scan(&id, &email); err != nil {
+            rows.Close()
+            return err
+        }
+        if normalized := normalizeEmail(email); normalized != email {
+            changed[id] = normalized
+        }
+    }
+    rows.Close()
+    if err := rows.Err(); err != nil {
+        return err
+    }
+
+    for id, email := range changed {
+        if _, err := tx.Exec(`UPDATE students SET email = ? WHERE id = ?`, email, id); err != nil {
+            return err
+        }
+    }
+    return tx.Commit()
+}
+
+// Close releases the underlying database handle
+func (st *SQLiteStore) Close() error {
+    return st.db.Close()
+}
+
+// Create inserts s under its own ID or, if that is empty, a newly generated one
+func (st *SQLiteStore) Create(ctx context.Context, s Student) (Student, error) {
+    created, itemErrs, err := st.CreateMany(ctx, []Student{s})
+    if err != nil {
+        return Student{}, err
+    }
+    if itemErrs[0] != nil {
+        return Student{}, itemErrs[0]
+    }
+    return created[0], nil
+}
+
+// CreateMany inserts students in a single transaction, skipping those whose
+// ID or email is already taken
+func (st *SQLiteStore) CreateMany(ctx context.Context, students []Student) ([]Student, []error, error) {
+    tx, err := st.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer tx.Rollback()
+
+    created := make([]Student, len(students))
+    itemErrs := make([]error, len(students))
+    for i, s := range students {
+        var err error
+        if s.ID != "" {
+            err = checkIDFree(ctx, tx, s.ID)
+        }
+        if err == nil {
+            err = checkEmailFree(ctx, tx, s.Email, "")
+        }
+        if err != nil {
+            if err != ErrDuplicateID && err != ErrDuplicateEmail {
+                return nil, nil, err
+            }
+            itemErrs[i] = err
+            continue
+        }
+        // IDs used by a batch that is later rolled back are not handed out
+        // again; sequences may have gaps but never go backwards
+        if s.ID == "" {
+            s.ID = st.ids.next()
+        } else {
+            st.ids.observe(s.ID)
+        }
+        _, err = tx.ExecContext(ctx,
+            `INSERT INTO students (`+studentColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+            s.ID, s.Name, s.Age, s.Email, s.CreatedAt, s.UpdatedAt, s.DeletedAt,
+            s.PendingEmail, s.emailTokenHash, s.emailTokenExpires, s.Active, s.version)
+        if err != nil {
+            return nil, nil, err
+        }
+        created[i] = s
+    }
+    return created, itemErrs, tx.Commit()
+}
+
+// checkIDFree returns ErrDuplicateID if a student, including a soft-deleted
+// one, already has the given ID
+func checkIDFree(ctx context.Context, tx *sql.Tx, id ID) error {
+    var taken bool
+    if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM students WHERE id = ?)`, id).Scan(&taken); err != nil {
+        return err
+    }
+    if taken {
+        return ErrDuplicateID
+    }
+    return nil
+}
+
+// checkEmailFree returns ErrDuplicateEmail if a student other than exceptID
+// uses email. The students_email index keeps this a cheap lookup; a UNIQUE
+// constraint isn't used since databases from older versions may already
+// contain duplicates.
+func checkEmailFree(ctx context.Context, tx *sql.Tx, email string, exceptID ID) error {
+    var taken bool
+    err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM students WHERE email = ? AND id != ?)`, email, exceptID).Scan(&taken)
+    if err != nil {
+        return err
+    }
+    if taken {
+        return ErrDuplicateEmail
+    }
+    return nil
+}
+
+// GetAll returns every student ordered by ID, as an empty rather than nil
+// slice when there are none
+func (st *SQLiteStore) GetAll(ctx context.Context) ([]Student, error) {
+    rows, err := st.db.QueryContext(ctx, `SELECT ` + studentColumns + ` FROM students ORDER BY id`)
+    if err != nil {
+        return nil, err
+    }
+    defer rows.Close()
+
+    studentList := []Student{}
+    for rows.Next() {
+        s, err := scanStudent(rows)
+        if err != nil {
+            return nil, err
+        }
+        studentList = append(studentList, s)
+    }
+    return studentList, rows.Err()
+}
+
+// GetByID returns the student with the given ID or ErrNotFound
+func (st *SQLiteStore) GetByID(ctx context.Context, id ID) (Student, error) {
+    s, err := scanStudent(st.db.QueryRowContext(ctx, `SELECT `+studentColumns+` FROM students WHERE id = ?`, id))
+    if err == sql.ErrNoRows {
+        return Student{}, ErrNotFound
+    }
+    return s, err
+}
+
+// Count returns the number of stored students
+func (st *SQLiteStore) Count(ctx context.Context) (int, error) {
+    var count int
+    err := st.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM students`).Scan(&count)
+    return count, err
+}
+
+// Modify applies fn to the student with the given ID within a transaction
+func (st *SQLiteStore) Modify(ctx context.Context, id ID, fn func(s *Student) error) (Student, error) {
+    tx, err := st.db.BeginTx(ctx, nil)
+    if err != nil {
+        return Student{}, err
+    }
+    defer tx.Rollback()
+
+    s, err := scanStudent(tx.QueryRowContext(ctx, `SELECT `+studentColumns+` FROM students WHERE id = ?`, id))
+    if err == sql.ErrNoRows {
+        return Student{}, ErrNotFound
+    }
+    if err != nil {
+        return Student{}, err
+    }
+    version := s.version
+    if err := fn(&s); err != nil {
+        return Student{}, err
+    }
+    s.ID = id
+    s.version = version + 1
+    if err := updateStudent(ctx, tx, s); err != nil {
+        return Student{}, err
+    }
+    return s, tx.Commit()
+}
+
+// ModifyMany applies fn to each of the students with the given IDs within a
+// single transaction
+func (st *SQLiteStore) ModifyMany(ctx context.Context, ids []ID, fn func(i int, s *Student) error) ([]Student, []error, error) {
+    tx, err := st.db.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, nil, err
+    }
+    defer tx.Rollback()
+
+    modified := make([]Student, len(ids))
+    itemErrs := make([]error, len(ids))
+    for i, id := range ids {
+        s, err := scanStudent(tx.QueryRowContext(ctx, `SELECT `+studentColumns+` FROM students WHERE id = ?`, id))
+        if err == sql.ErrNoRows {
+            itemErrs[i] = ErrNotFound
+            continue
+        }
+        if err != nil {
+            return nil, nil, err
+        }
+        version := s.version
+        if err := fn(i, &s); err != nil {
+            itemErrs[i] = err
+            continue
+        }
+        s.ID = id
+        s.version = version + 1
+        err = updateStudent(ctx, tx, s)
+        if err == ErrDuplicateEmail {
+            itemErrs[i] = err
+            continue
+        }
+        if err != nil {
+            return nil, nil, err
+        }
+        modified[i] = s
+    }
+    return modified, itemErrs, tx.Commit()
+}
+
+// updateStudent overwrites the row for s.ID, returning ErrNotFound if there
+// is none and ErrDuplicateEmail if another student has s.Email
+func updateStudent(ctx context.Context, tx *sql.Tx, s Student) error {
+    if err := checkEmailFree(ctx, tx, s.Email, s.ID); err != nil {
+        return err
+    }
+    res, err := tx.ExecContext(ctx,
+        `UPDATE students SET name = ?, age = ?, email = ?, created_at = ?, updated_at = ?, deleted_at = ?,
+            pending_email = ?, email_token_hash = ?, email_token_expires = ?, active = ?, version = ? WHERE id = ?`,
+        s.Name, s.Age, s.Email, s.CreatedAt, s.UpdatedAt, s.DeletedAt,
+        s.PendingEmail, s.emailTokenHash, s.emailTokenExpires, s.Active, s.version, s.ID)
+    if err != nil {
+        return err
+    }
+    return expectOneRow(res)
+}
+
+// DeleteAll removes every student. The ID sequence is left alone and saved,
+// so IDs aren't reused after a restart either.
+func (st *SQLiteStore) DeleteAll(ctx context.Context) (int, error) {
+    tx, err := st.db.BeginTx(ctx, nil)
+    if err != nil {
+        return 0, err
+    }
+    defer tx.Rollback()
+
+    if err := st.saveLastID(ctx, tx); err != nil {
+        return 0, err
+    }
+    res, err := tx.ExecContext(ctx, `DELETE FROM students`)
+    if err != nil {
+        return 0, err
+    }
+    n, err := res.RowsAffected()
+    if err != nil {
+        return 0, err
+    }
+    return int(n), tx.Commit()
+}
+
+// saveLastID records the highest numeric ID handed out or observed so far,
+// for NewSQLiteStore to seed the sequence from once the rows holding it are
+// gone. UUIDs aren't sequential and need nothing saved.
+func (st *SQLiteStore) saveLastID(ctx context.Context, tx *sql.Tx) error {
+    if st.ids.format != IDNumeric {
+        return nil
+    }
+    _, err := tx.ExecContext(ctx, `INSERT INTO store_meta (key, value) VALUES ('last_id', ?)
+        ON CONFLICT (key) DO UPDATE SET value = MAX(value, excluded.value)`, st.ids.last.Load())
+    return err
+}
+
+// Ping checks that the database file can still be queried
+func (st *SQLiteStore) Ping(ctx context.Context) error {
+    var one int
+    return st.db.QueryRowContext(ctx, `SELECT 1`).Scan(&one)
+}
+
+// IDFormat returns the format the store was opened with
+func (st *SQLiteStore) IDFormat() IDFormat {
+    return st.ids.format
+}
+
+// expectOneRow maps a statement that touched no rows to ErrNotFound
+func expectOneRow(res sql.Result) error {
+    n, err := res.RowsAffected()
+    if err != nil {
+        return err
+    }
+    if n == 0 {
+        return ErrNotFound
+    }
+    return nil
+}
@@ -0,0 +1,119 @@
+package students
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// domainCount is one entry returned by GET /students/stats/domains
+type domainCount struct {
+    Domain string `json:"domain"`
+    Count  int    `json:"count"`
+}
+
+// DomainStats handles GET /students/stats/domains, counting students per
+// email domain, most used first. Soft-deleted students are left out. The
+// counts come from a single GetAll snapshot, which the in-memory store takes
+// under its read lock.
+func (s *Server) DomainStats(w http.ResponseWriter, r *http.Request) {
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    counts := make(map[string]int)
+    for _, student := range studentList {
+        if student.DeletedAt != nil {
+            continue
+        }
+        counts[emailDomain(student.Email)]++
+    }
+
+    domains := []domainCount{}
+    for domain, count := range counts {
+        domains = append(domains, domainCount{Domain: domain, Count: count})
+    }
+    slices.SortFunc(domains, func(a, b domainCount) int {
+        if c := cmp.Compare(b.Count, a.Count); c != 0 {
+            return c
+        }
+        return strings.Compare(a.Domain, b.Domain)
+    })
+    s.writeJSON(w, r, map[string]interface{}{"data": domains})
+}
+
+// emailDomain returns the lower-cased part of email after the last @
+func emailDomain(email string) string {
+    return strings.ToLower(email[strings.LastIndexByte(email, '@')+1:])
+}
+
+// ageBucket counts the students whose age falls in [From, To]
+type ageBucket struct {
+    From  int `json:"from"`
+    To    int `json:"to"`
+    Count int `json:"count"`
+}
+
+// ageStats is the response to GET /students/stats/age. Every field is zero
+// when there are no students.
+type ageStats struct {
+    Count     int         `json:"count"`
+    Min       int         `json:"min"`
+    Max       int         `json:"max"`
+    Mean      float64     `json:"mean"`
+    Median    float64     `json:"median"`
+    Histogram []ageBucket `json:"histogram"`
+}
+
+// AgeStats handles GET /students/stats/age, summarising the ages of the
+// students that aren't soft-deleted. The histogram has one bucket per decade
+// from the youngest student's to the oldest's, empty decades included.
+func (s *Server) AgeStats(w http.ResponseWriter, r *http.Request) {
+    studentList, err := s.store.GetAll(r.Context())
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    var ages []int
+    for _, student := range studentList {
+        if student.DeletedAt == nil {
+            ages = append(ages, student.Age)
+        }
+    }
+    s.writeJSON(w, r, computeAgeStats(ages))
+}
+
+// computeAgeStats summarises ages, sorting them in place
+func computeAgeStats(ages []int) ageStats {
+    stats := ageStats{Count: len(ages), Histogram: []ageBucket{}}
+    if len(ages) == 0 {
+        return stats
+    }
+    slices.Sort(ages)
+    stats.Min, stats.Max = ages[0], ages[len(ages)-1]
+
+    sum := 0
+    for _, age := range ages {
+        sum += age
+    }
+    stats.Mean = float64(sum) / float64(len(ages))
+
+    mid := len(ages) / 2
+    if len(ages)%2 == 0 {
+        stats.Median = float64(ages[mid-1]+ages[mid]) / 2
+    } else {
+        stats.Median = float64(ages[mid])
+    }
+
+    for decade := stats.Min / 10 * 10; decade <= stats.Max; decade += 10 {
+        stats.Histogram = append(stats.Histogram, ageBucket{From: decade, To: decade + 9})
+    }
+    for _, age := range ages {
+        stats.Histogram[(age-stats.Min/10*10)/10].Count++
+    }
+    return stats
+}
@@ -0,0 +1,93 @@
+package students
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+// StudentStore is the persistence layer used by the HTTP handlers. Every
+// method takes the request's context so a cancelled or timed-out request
+// stops waiting on the store; the context's error is returned in that case.
+type StudentStore interface {
+    Create(ctx context.Context, s Student) (Student, error)
+    // CreateMany stores students in one atomic step, assigning an ID to each
+    // one whose ID is empty. itemErrs[i] is set when students[i] was rejected
+    // on its own (e.g. for a duplicate ID or email); err is set when the
+    // whole batch failed.
+    CreateMany(ctx context.Context, students []Student) (created []Student, itemErrs []error, err error)
+    // GetAll returns every student, soft-deleted ones included. An empty
+    // store yields an empty, non-nil slice.
+    GetAll(ctx context.Context) ([]Student, error)
+    GetByID(ctx context.Context, id ID) (Student, error)
+    // Count returns the number of stored students, soft-deleted ones included
+    Count(ctx context.Context) (int, error)
+    // Modify applies fn to the stored student with the given ID and saves
+    // the result, with no other write able to slip in between, one version
+    // on from the stored one. If fn returns an error nothing is saved and
+    // that error is returned.
+    Modify(ctx context.Context, id ID, fn func(s *Student) error) (Student, error)
+    // ModifyMany is Modify for many students in one atomic step: fn is
+    // applied to the student with ids[i], given i, and the results saved in
+    // order. itemErrs[i] is set when item i was skipped, whether for
+    // ErrNotFound, ErrDuplicateEmail or an error from fn; err is set when the
+    // whole batch failed.
+    ModifyMany(ctx context.Context, ids []ID, fn func(i int, s *Student) error) (modified []Student, itemErrs []error, err error)
+    // DeleteAll removes every student, soft-deleted ones included, and
+    // returns how many there were. IDs keep counting up from where they were.
+    DeleteAll(ctx context.Context) (int, error)
+    // Ping reports whether the store is usable
+    Ping(ctx context.Context) error
+    // IDFormat reports the format of the IDs the store hands out and accepts
+    IDFormat() IDFormat
+}
+
+var (
+    // ErrNotFound is returned when no student has the requested ID
+    ErrNotFound = errors.New("student not found")
+    // ErrDuplicateID is returned when a client-supplied ID is already in use
+    ErrDuplicateID = errors.New("student ID already exists")
+    // ErrDuplicateEmail is returned when another student, including a
+    // soft-deleted one, already uses the email
+    ErrDuplicateEmail = errors.New("email already exists")
+)
+
+// idSequence hands out student IDs in its format. Numeric IDs increase; the
+// sequence is seeded with the highest ID already stored, so they keep
+// increasing across restarts.
+type idSequence struct {
+    format IDFormat
+    last   atomic.Int64
+}
+
+// seed makes next continue after maxID
+func (seq *idSequence) seed(maxID int) {
+    seq.last.Store(int64(maxID))
+}
+
+// next returns a new ID. A numeric one is greater than every ID returned or
+// observed before.
+func (seq *idSequence) next() ID {
+    if seq.format == IDUUID {
+        return ID(uuid.NewString())
+    }
+    return ID(strconv.FormatInt(seq.last.Add(1), 10))
+}
+
+// observe records an ID chosen by a client so that next never hands it out.
+// Random UUIDs don't need this.
+func (seq *idSequence) observe(clientID ID) {
+    id, ok := clientID.numeric()
+    if !ok {
+        return
+    }
+    for {
+        last := seq.last.Load()
+        if int64(id) <= last || seq.last.CompareAndSwap(last, int64(id)) {
+            return
+        }
+    }
+}
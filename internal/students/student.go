@@ -0,0 +1,153 @@
+// Package students implements the student records API: the Student model,
+// its stores, the HTTP handlers and AI summaries generated through Ollama.
+package students
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// Student struct represents a student model
+type Student struct {
+    ID    ID     `json:"id"`
+    Name  string `json:"name"`
+    Age   int    `json:"age"`
+    Email string `json:"email"`
+
+    // Active starts out true and is flipped with the activate and deactivate
+    // endpoints; inactive students are kept and listed, unlike deleted ones
+    Active bool `json:"active"`
+
+    // Timestamps are set by the server; values sent by clients are ignored
+    CreatedAt time.Time  `json:"created_at"`
+    UpdatedAt time.Time  `json:"updated_at"`
+    DeletedAt *time.Time `json:"deleted_at,omitempty"` // Set when soft-deleted
+
+    // PendingEmail is a requested new email. Email stays in effect until the
+    // change is confirmed with the token handed out when it was requested.
+    PendingEmail      string    `json:"pending_email,omitempty"`
+    emailTokenHash    string    // SHA-256 of the confirmation token, hex-encoded
+    emailTokenExpires time.Time // When the confirmation token stops working
+
+    // version counts the changes saved since the student was created. Stores
+    // bump it with every Modify, whatever the callback sets it to.
+    version int
+}
+
+// newStudentInput is the body accepted when creating a student. ID shadows
+// the embedded Student.ID and is a pointer so that an explicit "id": "" can
+// be told apart from an omitted one.
+type newStudentInput struct {
+    Student
+    ID *ID `json:"id"`
+}
+
+// student returns the Student to create. Its ID is left empty, meaning
+// "assign one", unless the client supplied an ID valid in format.
+func (in newStudentInput) student(format IDFormat) (Student, error) {
+    s := in.Student
+    if in.ID != nil {
+        id, err := format.parse(string(*in.ID))
+        if err != nil {
+            return Student{}, ValidationErrors{{Field: "id", Message: err.Error()}}
+        }
+        s.ID = id
+    }
+    return s, nil
+}
+
+// studentPatch mirrors Student with pointer fields so a PATCH body can
+// distinguish an omitted field from one set to its zero value
+type studentPatch struct {
+    Name  *string `json:"name"`
+    Age   *int    `json:"age"`
+    Email *string `json:"email"`
+}
+
+// apply copies the fields present in the patch onto s
+func (p studentPatch) apply(s *Student) {
+    if p.Name != nil {
+        s.Name = *p.Name
+    }
+    if p.Age != nil {
+        s.Age = *p.Age
+    }
+    if p.Email != nil {
+        s.Email = *p.Email
+    }
+}
+
+// Accepted bounds for Student.Age
+const (
+    minAge = 1
+    maxAge = 150
+)
+
+// normalizeStudent cleans up client-supplied fields before validation
+func normalizeStudent(s *Student) {
+    s.Name = strings.TrimSpace(s.Name)
+    s.Email = normalizeEmail(s.Email)
+}
+
+// normalizeEmail trims and lower-cases an email so that addresses differing
+// only in case are stored, and checked for duplicates, as the same one.
+// Strictly the part before the @ is case-sensitive, but no mail provider in
+// practice treats it that way.
+func normalizeEmail(email string) string {
+    return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidationError describes one problem with one field of a request body.
+// Field is a dotted path such as "email", or empty for the body as a whole.
+type ValidationError struct {
+    Field   string `json:"field"`
+    Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+    return strings.TrimSpace(e.Field + " " + e.Message)
+}
+
+// ValidationErrors lists every problem found with a request body. Handlers
+// send it as {"errors": [...]} with status 422; callers of the package can
+// pick it out of a returned error with errors.As.
+type ValidationErrors []ValidationError
+
+func (v ValidationErrors) Error() string {
+    msgs := make([]string, len(v))
+    for i, e := range v {
+        msgs[i] = e.Error()
+    }
+    return strings.Join(msgs, "; ")
+}
+
+// validateStudent checks the client-supplied fields of a student, returning
+// ValidationErrors covering every invalid field
+func validateStudent(s Student) error {
+    var errs ValidationErrors
+    if s.Name == "" {
+        errs = append(errs, ValidationError{Field: "name", Message: "is required"})
+    }
+    switch {
+    case s.Age == 0:
+        errs = append(errs, ValidationError{Field: "age", Message: "is required"})
+    case s.Age < minAge || s.Age > maxAge:
+        errs = append(errs, ValidationError{Field: "age", Message: fmt.Sprintf("must be between %d and %d", minAge, maxAge)})
+    }
+    if !validEmail(s.Email) {
+        errs = append(errs, ValidationError{Field: "email", Message: "must be a valid email"})
+    }
+    if len(errs) > 0 {
+        return errs
+    }
+    return nil
+}
+
+// validEmail reports whether email is a bare address, without a display
+// name or angle brackets
+func validEmail(email string) bool {
+    addr, err := mail.ParseAddress(email)
+    return err == nil && addr.Address == email
+}
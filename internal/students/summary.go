@@ -0,0 +1,424 @@
+package students
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// GetStudentSummary generates a summary using the Ollama API. Summaries are
+// cached per student until the record changes or ?refresh=true is passed. If
+// Ollama is down, the last summary generated for the student is returned
+// even if outdated, marked with a Warning header.
+// ?dry_run=true returns the prompt that would be sent instead of calling
+// Ollama, ?model= picks one of the allowed models instead of the default,
+// and ?max_words= and ?style=short|detailed ask for a summary of a given
+// length.
+func (s *Server) GetStudentSummary(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+    opts, err := s.ollama.parseSummaryOptions(r.URL.Query())
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+    slog.Debug("Summary requested", "student_id", id)
+
+    student, err := s.getStudent(r.Context(), id, false)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    if r.URL.Query().Get("dry_run") == "true" {
+        prompt, err := s.ollama.buildSummaryPrompt(student, opts)
+        if err != nil {
+            slog.Error("Failed to build prompt", "err", err)
+            WriteJSONError(w, http.StatusInternalServerError, "Failed to build prompt")
+            return
+        }
+        s.writeJSON(w, r, map[string]string{"prompt": prompt})
+        return
+    }
+
+    if r.URL.Query().Get("refresh") != "true" {
+        if summary, ok := s.summaries.get(student, opts); ok {
+            s.writeSummary(w, r, summary)
+            return
+        }
+    }
+
+    summary, err := s.generateSummary(r.Context(), student, opts)
+    if err != nil {
+        slog.Error("Summary failed", "student_id", id, "err", err)
+        // An outdated summary beats none while Ollama is down
+        if stale, ok := s.summaries.getStale(student, opts); ok && ollamaDown(err) {
+            w.Header().Set("Warning", `110 - "Response is Stale"`)
+            s.writeSummary(w, r, stale)
+            return
+        }
+        writeSummaryError(w, err)
+        return
+    }
+
+    s.writeSummary(w, r, summary)
+}
+
+// ollamaDown reports whether err means Ollama couldn't be used at all, as
+// opposed to failing on this particular request
+func ollamaDown(err error) bool {
+    var openErr *CircuitOpenError
+    return errors.Is(err, ErrOllamaUnavailable) || isTimeout(err) || errors.As(err, &openErr)
+}
+
+// RefreshStudentSummary handles POST /students/{id}/summary/refresh. It
+// always asks Ollama for a new summary and caches it, so batch jobs can warm
+// the cache ahead of reads. ?model=, ?max_words= and ?style= work as for
+// GET /students/{id}/summary.
+func (s *Server) RefreshStudentSummary(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+    opts, err := s.ollama.parseSummaryOptions(r.URL.Query())
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    student, err := s.getStudent(r.Context(), id, false)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    summary, err := s.generateSummary(r.Context(), student, opts)
+    if err != nil {
+        slog.Error("Summary failed", "student_id", id, "err", err)
+        writeSummaryError(w, err)
+        return
+    }
+
+    s.writeSummary(w, r, summary)
+}
+
+// writeSummaryError responds to a failed summary generation, telling the
+// client when to retry if the circuit breaker is open
+func writeSummaryError(w http.ResponseWriter, err error) {
+    var openErr *CircuitOpenError
+    if errors.As(err, &openErr) {
+        w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(openErr.RetryAfter.Seconds()))))
+    }
+    status, msg := summaryErrorStatus(err)
+    WriteJSONError(w, status, msg)
+}
+
+// summaryErrorStatus maps a failed summary generation to an HTTP status and
+// a client-safe message, so clients can tell which failures are worth
+// retrying
+func summaryErrorStatus(err error) (int, string) {
+    var ollamaErr *OllamaError
+    var openErr *CircuitOpenError
+    switch {
+    case errors.As(err, &openErr):
+        return http.StatusServiceUnavailable, "Ollama API is failing, try again later"
+    case errors.As(err, &ollamaErr):
+        return http.StatusBadGateway, ollamaErr.Error()
+    case errors.Is(err, ErrOllamaBusy):
+        return http.StatusTooManyRequests, "Too many summaries are being generated, try again later"
+    case isTimeout(err):
+        return http.StatusGatewayTimeout, "Timed out waiting for Ollama API"
+    case errors.Is(err, ErrOllamaUnavailable):
+        return http.StatusBadGateway, "Ollama API is unreachable or returned an error"
+    case errors.Is(err, ErrOllamaUnexpectedContent):
+        return http.StatusBadGateway, "Ollama API returned unexpected content instead of JSON; check that OLLAMA_URL points at Ollama"
+    case errors.Is(err, ErrOllamaBadResponse):
+        return http.StatusInternalServerError, "Ollama API returned a malformed response"
+    }
+    return http.StatusInternalServerError, "Failed to generate summary"
+}
+
+// generateSummary asks Ollama for a summary of student and caches it.
+// Concurrent requests for the same version of a student and options share one
+// Ollama call. A caller that gives up stops waiting without failing the
+// others; the call itself is cancelled once every caller has given up, and
+// otherwise ends by the deadline of the request that started it.
+func (s *Server) generateSummary(ctx context.Context, student Student, opts summaryOptions) (string, error) {
+    key := studentHash(student) + "\x00" + opts.key()
+    callCtx, leave := s.inflight.join(ctx, key)
+    defer leave()
+
+    leader := false // Only the caller whose function runs makes the Ollama call
+    ch := s.inflight.group.DoChan(key, func() (interface{}, error) {
+        leader = true
+        summary, err := s.ollama.callOllamaAPI(callCtx, student, opts)
+        if err != nil {
+            return "", err
+        }
+        s.summaries.put(student, opts, summary)
+        return summary, nil
+    })
+    select {
+    case res := <-ch:
+        if !leader {
+            summaryRequestsShared.Inc()
+        }
+        return res.Val.(string), res.Err
+    case <-ctx.Done():
+        return "", ctx.Err()
+    }
+}
+
+// summaryFlights tracks the Ollama calls shared between summary requests.
+// Its zero value is ready to use.
+type summaryFlights struct {
+    group singleflight.Group
+
+    mu      sync.Mutex
+    flights map[string]*summaryFlight
+}
+
+// summaryFlight is the context shared by the callers waiting on one key
+type summaryFlight struct {
+    ctx     context.Context
+    cancel  context.CancelFunc
+    waiters int
+}
+
+// join registers a caller waiting for the call under key, returning the
+// context the call should run with and a function to call once the caller
+// stops waiting. The context doesn't end with the caller's own, only when
+// the last caller leaves or the first caller's deadline passes.
+func (f *summaryFlights) join(ctx context.Context, key string) (context.Context, func()) {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+
+    flight := f.flights[key]
+    if flight == nil {
+        flight = &summaryFlight{}
+        detached := context.WithoutCancel(ctx)
+        if deadline, ok := ctx.Deadline(); ok {
+            flight.ctx, flight.cancel = context.WithDeadline(detached, deadline)
+        } else {
+            flight.ctx, flight.cancel = context.WithCancel(detached)
+        }
+        if f.flights == nil {
+            f.flights = make(map[string]*summaryFlight)
+        }
+        f.flights[key] = flight
+    }
+    flight.waiters++
+
+    return flight.ctx, func() {
+        f.mu.Lock()
+        defer f.mu.Unlock()
+        flight.waiters--
+        if flight.waiters == 0 {
+            flight.cancel()
+            delete(f.flights, key)
+            // A call still running with the cancelled context mustn't be
+            // handed to the next caller
+            f.group.Forget(key)
+        }
+    }
+}
+
+// summaryBatchRequest is the body of POST /students/summaries
+type summaryBatchRequest struct {
+    IDs []ID `json:"ids"`
+}
+
+// summaryBatchResult is the outcome for one ID of a batch: either the
+// summary or the status and error a single summary request would have given
+type summaryBatchResult struct {
+    ID      ID     `json:"id"`
+    Summary string `json:"summary,omitempty"`
+    Status  int    `json:"status"`
+    Error   string `json:"error,omitempty"`
+}
+
+// BatchStudentSummaries handles POST /students/summaries with a body of
+// {"ids": [...]}, returning a result per ID in the order given. Summaries
+// are generated by a pool of OllamaConfig.BatchConcurrency workers, so a
+// slow student holds up only its own worker, and cached summaries are reused.
+// Duplicate IDs are summarised once.
+func (s *Server) BatchStudentSummaries(w http.ResponseWriter, r *http.Request) {
+    var req summaryBatchRequest
+    if !s.decodeJSONBody(w, r, summaryBatchSchema, &req) {
+        return
+    }
+    var ids []ID
+    seen := make(map[ID]bool)
+    for i, rawID := range req.IDs {
+        id, err := s.store.IDFormat().parse(string(rawID))
+        if err != nil {
+            writeValidationError(w, ValidationErrors{{Field: fmt.Sprintf("ids.%d", i), Message: err.Error()}})
+            return
+        }
+        if !seen[id] {
+            seen[id] = true
+            ids = append(ids, id)
+        }
+    }
+
+    ctx := r.Context()
+    results := make([]summaryBatchResult, len(ids))
+    jobs := make(chan int)
+    var wg sync.WaitGroup
+    for range min(max(s.ollama.cfg.BatchConcurrency, 1), len(ids)) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            for i := range jobs {
+                results[i] = s.batchSummary(ctx, ids[i])
+            }
+        }()
+    }
+feed:
+    for i := range ids {
+        select {
+        case jobs <- i:
+        case <-ctx.Done():
+            break feed
+        }
+    }
+    close(jobs)
+    wg.Wait()
+
+    if ctx.Err() != nil {
+        slog.Info("Summary batch aborted, client disconnected")
+        return
+    }
+    s.writeJSON(w, r, map[string]interface{}{"results": results})
+}
+
+// batchSummary produces the result for one ID of a batch
+func (s *Server) batchSummary(ctx context.Context, id ID) summaryBatchResult {
+    student, err := s.getStudent(ctx, id, false)
+    if errors.Is(err, ErrNotFound) {
+        return summaryBatchResult{ID: id, Status: http.StatusNotFound, Error: "Student not found"}
+    }
+    if err != nil {
+        slog.Error("Store error", "err", err)
+        return summaryBatchResult{ID: id, Status: http.StatusInternalServerError, Error: "Internal server error"}
+    }
+
+    opts := summaryOptions{model: s.ollama.cfg.Model}
+    if summary, ok := s.summaries.get(student, opts); ok {
+        return summaryBatchResult{ID: id, Summary: summary, Status: http.StatusOK}
+    }
+    summary, err := s.generateSummary(ctx, student, opts)
+    if err != nil {
+        slog.Error("Summary failed", "student_id", id, "err", err)
+        status, msg := summaryErrorStatus(err)
+        return summaryBatchResult{ID: id, Status: status, Error: msg}
+    }
+    return summaryBatchResult{ID: id, Summary: summary, Status: http.StatusOK}
+}
+
+// writeSummary sends summary as {"summary": ...} or, if the client's Accept
+// header prefers it, as bare text/plain
+func (s *Server) writeSummary(w http.ResponseWriter, r *http.Request, summary string) {
+    if negotiateContentType(r, "application/json", "text/plain") == "text/plain" {
+        w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+        io.WriteString(w, summary)
+        return
+    }
+    s.writeJSON(w, r, map[string]string{"summary": summary})
+}
+
+// GetStudentSummaryStream handles GET /students/{id}/summary/stream, relaying
+// the summary as Server-Sent Events while Ollama generates it. ?model=,
+// ?max_words= and ?style= work as for GET /students/{id}/summary.
+func (s *Server) GetStudentSummaryStream(w http.ResponseWriter, r *http.Request) {
+    id, err := s.pathID(r)
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, "Invalid ID")
+        return
+    }
+    opts, err := s.ollama.parseSummaryOptions(r.URL.Query())
+    if err != nil {
+        WriteJSONError(w, http.StatusBadRequest, err.Error())
+        return
+    }
+
+    student, err := s.getStudent(r.Context(), id, false)
+    if err != nil {
+        writeStoreError(w, err)
+        return
+    }
+
+    prompt, err := s.ollama.buildSummaryPrompt(student, opts)
+    if err != nil {
+        slog.Error("Failed to build prompt", "err", err)
+        WriteJSONError(w, http.StatusInternalServerError, "Failed to build prompt")
+        return
+    }
+
+    flusher, ok := w.(http.Flusher)
+    if !ok {
+        WriteJSONError(w, http.StatusInternalServerError, "Streaming not supported")
+        return
+    }
+    w.Header().Set("Content-Type", "text/event-stream")
+    w.Header().Set("Cache-Control", "no-cache")
+    w.Header().Set("Connection", "keep-alive")
+    w.WriteHeader(http.StatusOK)
+    flusher.Flush()
+
+    // The request context is cancelled when the client goes away, which
+    // aborts the upstream Ollama call as well
+    err = s.ollama.streamOllamaAPI(r.Context(), prompt, opts.model, func(text string) error {
+        if err := writeSSE(w, "", map[string]string{"response": text}); err != nil {
+            return err
+        }
+        flusher.Flush()
+        return nil
+    })
+    if errors.Is(r.Context().Err(), context.DeadlineExceeded) {
+        slog.Warn("Summary stream timed out", "student_id", id)
+        writeSSE(w, "error", map[string]string{"error": "Request timed out"})
+        flusher.Flush()
+        return
+    }
+    if r.Context().Err() != nil {
+        slog.Info("Summary stream aborted, client disconnected", "student_id", id)
+        return
+    }
+    if err != nil {
+        slog.Error("Summary stream failed", "student_id", id, "err", err)
+        _, msg := summaryErrorStatus(err)
+        writeSSE(w, "error", map[string]string{"error": msg})
+    } else {
+        writeSSE(w, "done", map[string]bool{"done": true})
+    }
+    flusher.Flush()
+}
+
+// writeSSE writes a single Server-Sent Event whose data is v encoded as JSON
+func writeSSE(w io.Writer, event string, v interface{}) error {
+    data, err := json.Marshal(v)
+    if err != nil {
+        return err
+    }
+    if event != "" {
+        if _, err := fmt.Fprintf(w, "event: %s\n", event); err != nil {
+            return err
+        }
+    }
+    _, err = fmt.Fprintf(w, "data: %s\n\n", data)
+    return err
+}
@@ -0,0 +1,141 @@
+package students
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultSummaryCacheTTL is how long a cached summary is used unless
+// Server.SetSummaryCacheTTL says otherwise
+const DefaultSummaryCacheTTL = time.Hour
+
+// summaryCache remembers the last summary generated for each student so
+// repeated requests don't go back to the LLM. Asking for a summary from
+// another model or length replaces it. Summaries older than ttl are treated as
+// missing, and removed by evictExpired; a zero ttl keeps them forever.
+type summaryCache struct {
+    mu      sync.Mutex
+    ttl     time.Duration
+    entries map[ID]summaryCacheEntry
+}
+
+type summaryCacheEntry struct {
+    hash    string // studentHash of the record the summary was generated from
+    opts    summaryOptions
+    summary string
+    expires time.Time // Zero if the entry never expires
+}
+
+// expired reports whether the entry is too old to be used at now
+func (e summaryCacheEntry) expired(now time.Time) bool {
+    return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func newSummaryCache(ttl time.Duration) *summaryCache {
+    return &summaryCache{ttl: ttl, entries: make(map[ID]summaryCacheEntry)}
+}
+
+// get returns the cached summary for student, provided it was generated
+// with opts from the same field values
+func (c *summaryCache) get(student Student, opts summaryOptions) (string, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    entry, ok := c.entries[student.ID]
+    if !ok || entry.hash != studentHash(student) || entry.opts != opts || entry.expired(time.Now()) {
+        summaryCacheMisses.Inc()
+        return "", false
+    }
+    summaryCacheHits.Inc()
+    return entry.summary, true
+}
+
+// getStale returns the last summary generated with opts for student even if the
+// record has changed since, for use when a fresh one can't be had. Expired
+// summaries are still too old.
+func (c *summaryCache) getStale(student Student, opts summaryOptions) (string, bool) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    entry, ok := c.entries[student.ID]
+    if !ok || entry.opts != opts || entry.expired(time.Now()) {
+        return "", false
+    }
+    return entry.summary, true
+}
+
+// put stores summary, generated with opts, as the current summary for student
+func (c *summaryCache) put(student Student, opts summaryOptions, summary string) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    entry := summaryCacheEntry{hash: studentHash(student), opts: opts, summary: summary}
+    if c.ttl > 0 {
+        entry.expires = time.Now().Add(c.ttl)
+    }
+    c.entries[student.ID] = entry
+}
+
+// invalidate marks any cached summary for the given student as outdated, so
+// get no longer returns it but getStale still does
+func (c *summaryCache) invalidate(id ID) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    if entry, ok := c.entries[id]; ok {
+        entry.hash = ""
+        c.entries[id] = entry
+    }
+}
+
+// evictExpired removes the expired summaries, returning how many there were
+func (c *summaryCache) evictExpired() int {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+
+    now := time.Now()
+    evicted := 0
+    for id, entry := range c.entries {
+        if entry.expired(now) {
+            delete(c.entries, id)
+            evicted++
+        }
+    }
+    return evicted
+}
+
+// runEviction calls evictExpired every ttl until ctx is done. It returns at
+// once if summaries never expire.
+func (c *summaryCache) runEviction(ctx context.Context) {
+    if c.ttl <= 0 {
+        return
+    }
+    ticker := time.NewTicker(c.ttl)
+    defer ticker.Stop()
+    for {
+        select {
+        case <-ticker.C:
+            if n := c.evictExpired(); n > 0 {
+                slog.Debug("Evicted expired summaries", "count", n)
+            }
+        case <-ctx.Done():
+            return
+        }
+    }
+}
+
+// clear drops every cached summary
+func (c *summaryCache) clear() {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    clear(c.entries)
+}
+
+// studentHash fingerprints the fields of a student
+func studentHash(s Student) string {
+    sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%s\x00%d\x00%s", s.ID, s.Name, s.Age, s.Email)))
+    return hex.EncodeToString(sum[:])
+}
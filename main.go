@@ -1,231 +1,466 @@
 package main
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
-	"math/rand"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"strconv"
-	"sync"
+	"strings"
+	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/gorilla/mux"
-)
-
-// Student struct represents a student model
-type Student struct {
-    ID    int    `json:"id"`
-    Name  string `json:"name"`
-    Age   int    `json:"age"`
-    Email string `json:"email"`
-}
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
-var (
-    students = make(map[int]Student) // In-memory data storage
-    mu       sync.Mutex              // Mutex to handle concurrent access
+	"student_api/internal/students"
 )
 
-// Generate ID for new students
-func generateID() int {
-    rand.Seed(time.Now().UnixNano())
-    return rand.Intn(10000)
-}
-
-// CreateStudent handles POST /students to create a new student
-func CreateStudent(w http.ResponseWriter, r *http.Request) {
-    var student Student
-    if err := json.NewDecoder(r.Body).Decode(&student); err != nil {
-        http.Error(w, "Invalid input", http.StatusBadRequest)
-        return
+// getEnv returns the value of the environment variable key or fallback if unset
+func getEnv(key, fallback string) string {
+    if v, ok := os.LookupEnv(key); ok {
+        return v
     }
-
-    mu.Lock()
-    student.ID = generateID()
-    students[student.ID] = student
-    mu.Unlock()
-
-    w.WriteHeader(http.StatusCreated)
-    json.NewEncoder(w).Encode(student)
+    return fallback
 }
 
-// GetStudents handles GET /students to retrieve all students
-func GetStudents(w http.ResponseWriter, r *http.Request) {
-    mu.Lock()
-    defer mu.Unlock()
-
-    var studentList []Student
-    for _, student := range students {
-        studentList = append(studentList, student)
+// getEnvDuration parses the environment variable key as a time.Duration,
+// returning fallback if it is unset
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+    v, ok := os.LookupEnv(key)
+    if !ok {
+        return fallback, nil
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %v", key, err)
     }
-    json.NewEncoder(w).Encode(studentList)
+    return d, nil
 }
 
-// GetStudentByID handles GET /students/{id} to retrieve a student by ID
-func GetStudentByID(w http.ResponseWriter, r *http.Request) {
-    id, err := strconv.Atoi(mux.Vars(r)["id"])
+// getEnvInt parses the environment variable key as an integer, returning
+// fallback if it is unset
+func getEnvInt(key string, fallback int) (int, error) {
+    v, ok := os.LookupEnv(key)
+    if !ok {
+        return fallback, nil
+    }
+    n, err := strconv.Atoi(v)
     if err != nil {
-        http.Error(w, "Invalid ID", http.StatusBadRequest)
-        return
+        return 0, fmt.Errorf("invalid %s: %v", key, err)
     }
+    return n, nil
+}
 
-    mu.Lock()
-    student, exists := students[id]
-    mu.Unlock()
-
-    if !exists {
-        http.Error(w, "Student not found", http.StatusNotFound)
-        return
+// getEnvFloat parses the environment variable key as a float, returning
+// fallback if it is unset
+func getEnvFloat(key string, fallback float64) (float64, error) {
+    v, ok := os.LookupEnv(key)
+    if !ok {
+        return fallback, nil
     }
-    json.NewEncoder(w).Encode(student)
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil {
+        return 0, fmt.Errorf("invalid %s: %v", key, err)
+    }
+    return f, nil
 }
 
-// UpdateStudentByID handles PUT /students/{id} to update a student by ID
-func UpdateStudentByID(w http.ResponseWriter, r *http.Request) {
-    id, err := strconv.Atoi(mux.Vars(r)["id"])
+// getEnvBool parses the environment variable key as a boolean, returning
+// fallback if it is unset
+func getEnvBool(key string, fallback bool) (bool, error) {
+    v, ok := os.LookupEnv(key)
+    if !ok {
+        return fallback, nil
+    }
+    b, err := strconv.ParseBool(v)
     if err != nil {
-        http.Error(w, "Invalid ID", http.StatusBadRequest)
-        return
+        return false, fmt.Errorf("invalid %s: %v", key, err)
     }
+    return b, nil
+}
 
-    var updatedStudent Student
-    if err := json.NewDecoder(r.Body).Decode(&updatedStudent); err != nil {
-        http.Error(w, "Invalid input", http.StatusBadRequest)
-        return
+// getEnvList splits a comma-separated environment variable, returning
+// fallback if it is unset
+func getEnvList(key string, fallback []string) []string {
+    v, ok := os.LookupEnv(key)
+    if !ok {
+        return fallback
     }
+    var list []string
+    for _, item := range strings.Split(v, ",") {
+        if item = strings.TrimSpace(item); item != "" {
+            list = append(list, item)
+        }
+    }
+    return list
+}
 
-    mu.Lock()
-    if _, exists := students[id]; !exists {
-        mu.Unlock()
-        http.Error(w, "Student not found", http.StatusNotFound)
-        return
+// newLogger builds the process logger from LOG_LEVEL (debug, info, warn or
+// error; default info) and LOG_FORMAT (text or json; default text)
+func newLogger() (*slog.Logger, error) {
+    var level slog.Level
+    if err := level.UnmarshalText([]byte(getEnv("LOG_LEVEL", "info"))); err != nil {
+        return nil, fmt.Errorf("invalid LOG_LEVEL: %v", err)
     }
-    updatedStudent.ID = id
-    students[id] = updatedStudent
-    mu.Unlock()
+    opts := &slog.HandlerOptions{Level: level}
+    switch format := getEnv("LOG_FORMAT", "text"); format {
+    case "text":
+        return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+    case "json":
+        return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+    default:
+        return nil, fmt.Errorf("invalid LOG_FORMAT %q: want text or json", format)
+    }
+}
 
-    json.NewEncoder(w).Encode(updatedStudent)
+// fatal logs a startup failure and exits
+func fatal(err error) {
+    slog.Error(err.Error())
+    os.Exit(1)
 }
 
-// DeleteStudentByID handles DELETE /students/{id} to delete a student by ID
-func DeleteStudentByID(w http.ResponseWriter, r *http.Request) {
-    id, err := strconv.Atoi(mux.Vars(r)["id"])
-    if err != nil {
-        http.Error(w, "Invalid ID", http.StatusBadRequest)
-        return
+// resolvePort picks the listen port from the -port flag, then the PORT
+// environment variable, then the 8080 default
+func resolvePort(flagPort string) (int, error) {
+    raw := flagPort
+    if raw == "" {
+        raw = getEnv("PORT", "8080")
     }
-
-    mu.Lock()
-    if _, exists := students[id]; !exists {
-        mu.Unlock()
-        http.Error(w, "Student not found", http.StatusNotFound)
-        return
+    port, err := strconv.Atoi(raw)
+    if err != nil || port < 1 || port > 65535 {
+        return 0, fmt.Errorf("invalid port %q: must be a number between 1 and 65535", raw)
     }
-    delete(students, id)
-    mu.Unlock()
-
-    w.WriteHeader(http.StatusNoContent)
+    return port, nil
 }
 
-// GetStudentSummary generates a summary using the Ollama API
-func GetStudentSummary(w http.ResponseWriter, r *http.Request) {
-	log.Println("GetStudentSummary called") 
-    id, err := strconv.Atoi(mux.Vars(r)["id"])
+// loadOllamaConfig reads the Ollama settings from the environment
+func loadOllamaConfig() (students.OllamaConfig, error) {
+    timeout, err := getEnvDuration("OLLAMA_TIMEOUT", 30*time.Second)
     if err != nil {
-        http.Error(w, "Invalid ID", http.StatusBadRequest)
-        return
+        return students.OllamaConfig{}, err
     }
-
-    mu.Lock()
-    student, exists := students[id]
-    mu.Unlock()
-
-    if !exists {
-        http.Error(w, "Student not found", http.StatusNotFound)
-        return
+    maxAttempts, err := getEnvInt("OLLAMA_MAX_ATTEMPTS", 3)
+    if err != nil {
+        return students.OllamaConfig{}, err
     }
-
-    summary, err := callOllamaAPI(student)
+    if maxAttempts < 1 {
+        return students.OllamaConfig{}, errors.New("invalid OLLAMA_MAX_ATTEMPTS: must be at least 1")
+    }
+    maxConcurrent, err := getEnvInt("OLLAMA_MAX_CONCURRENT", 4)
     if err != nil {
-        http.Error(w, "Failed to generate summary", http.StatusInternalServerError)
-        return
+        return students.OllamaConfig{}, err
     }
-
-    json.NewEncoder(w).Encode(map[string]string{"summary": summary})
+    if maxConcurrent < 0 {
+        return students.OllamaConfig{}, errors.New("invalid OLLAMA_MAX_CONCURRENT: must not be negative")
+    }
+    queueTimeout, err := getEnvDuration("OLLAMA_QUEUE_TIMEOUT", 10*time.Second)
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    batchConcurrency, err := getEnvInt("OLLAMA_BATCH_CONCURRENCY", 4)
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    if batchConcurrency < 1 {
+        return students.OllamaConfig{}, errors.New("invalid OLLAMA_BATCH_CONCURRENCY: must be at least 1")
+    }
+    breakerThreshold, err := getEnvInt("OLLAMA_BREAKER_THRESHOLD", 5)
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    if breakerThreshold < 0 {
+        return students.OllamaConfig{}, errors.New("invalid OLLAMA_BREAKER_THRESHOLD: must not be negative")
+    }
+    breakerCooldown, err := getEnvDuration("OLLAMA_BREAKER_COOLDOWN", 30*time.Second)
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    stream, err := getEnvBool("OLLAMA_STREAM", true)
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    promptTemplate, err := loadPromptTemplate()
+    if err != nil {
+        return students.OllamaConfig{}, err
+    }
+    return students.OllamaConfig{
+        BaseURL:          strings.TrimSuffix(getEnv("OLLAMA_URL", "http://localhost:11434"), "/"),
+        Model:            getEnv("OLLAMA_MODEL", "llama3.2"),
+        Models:           getEnvList("OLLAMA_MODELS", nil),
+        Timeout:          timeout,
+        MaxAttempts:      maxAttempts,
+        MaxConcurrent:    maxConcurrent,
+        QueueTimeout:     queueTimeout,
+        BatchConcurrency: batchConcurrency,
+        BreakerThreshold: breakerThreshold,
+        BreakerCooldown:  breakerCooldown,
+        DisableStreaming: !stream,
+        PromptTemplate:   promptTemplate,
+    }, nil
 }
 
-// callOllamaAPI makes a call to the Ollama API to generate an AI-based summary
-func callOllamaAPI(student Student) (string, error) {
-    const ollamaURL = "http://localhost:11434/api/generate"
+// loadPromptTemplate reads the summary prompt template from the file named
+// by OLLAMA_PROMPT_TEMPLATE_FILE or inline from OLLAMA_PROMPT_TEMPLATE,
+// returning nil for the default when neither is set
+func loadPromptTemplate() (*template.Template, error) {
+    text, inline := os.LookupEnv("OLLAMA_PROMPT_TEMPLATE")
+    if path, ok := os.LookupEnv("OLLAMA_PROMPT_TEMPLATE_FILE"); ok {
+        if inline {
+            return nil, errors.New("set only one of OLLAMA_PROMPT_TEMPLATE and OLLAMA_PROMPT_TEMPLATE_FILE")
+        }
+        data, err := os.ReadFile(path)
+        if err != nil {
+            return nil, fmt.Errorf("invalid OLLAMA_PROMPT_TEMPLATE_FILE: %v", err)
+        }
+        text = string(data)
+    } else if !inline {
+        return nil, nil
+    }
+    return students.ParsePromptTemplate(text)
+}
 
-    prompt := fmt.Sprintf("Summarize this given information about the student with ID %d. The student's name is %s, they are %d years old, and their email is %s in a paragraph", student.ID, student.Name, student.Age, student.Email)
+// Default request body limits; the bulk limit applies to the bulk create and
+// CSV import endpoints
+const (
+    defaultMaxBodyBytes     = 1 << 20
+    defaultMaxBulkBodyBytes = 10 << 20
+)
 
+func main() {
+    portFlag := flag.String("port", "", "port to listen on (overrides $PORT)")
+    flag.Parse()
 
-    // Prepare the request payload
-    requestPayload := map[string]string{
-        "model":  "llama3.2",
-        "prompt": prompt,
+    logger, err := newLogger()
+    if err != nil {
+        log.Fatal(err)
     }
+    slog.SetDefault(logger)
 
-    requestBody, err := json.Marshal(requestPayload)
+    port, err := resolvePort(*portFlag)
     if err != nil {
-        return "", fmt.Errorf("Failed to encode request payload: %v", err)
+        fatal(err)
     }
-
-    req, err := http.NewRequest("POST", ollamaURL, bytes.NewBuffer(requestBody))
+    shutdownTimeout, err := getEnvDuration("SHUTDOWN_TIMEOUT", 10*time.Second)
     if err != nil {
-        return "", fmt.Errorf("Failed to create request: %v", err)
+        fatal(err)
     }
-    req.Header.Set("Content-Type", "application/json")
-
-    client := &http.Client{}
-    resp, err := client.Do(req)
+    ollamaConfig, err := loadOllamaConfig()
     if err != nil {
-        return "", fmt.Errorf("Failed to reach Ollama API: %v", err)
+        fatal(err)
     }
-    defer resp.Body.Close()
-
-    if resp.StatusCode != http.StatusOK {
-        return "", fmt.Errorf("Ollama API returned non-200 status: %d", resp.StatusCode)
+    generalLimiter, summaryLimiter, err := loadRateLimiters()
+    if err != nil {
+        fatal(err)
+    }
+    maxBodyBytes, err := getEnvInt("MAX_BODY_BYTES", defaultMaxBodyBytes)
+    if err != nil {
+        fatal(err)
+    }
+    maxBulkBodyBytes, err := getEnvInt("MAX_BULK_BODY_BYTES", defaultMaxBulkBodyBytes)
+    if err != nil {
+        fatal(err)
+    }
+    if maxBodyBytes < 1 || maxBulkBodyBytes < 1 {
+        fatal(errors.New("invalid MAX_BODY_BYTES or MAX_BULK_BODY_BYTES: must be at least 1"))
+    }
+    requestTimeout, err := getEnvDuration("REQUEST_TIMEOUT", 15*time.Second)
+    if err != nil {
+        fatal(err)
+    }
+    // Connection-level limits, so slow clients can't hold connections open
+    // indefinitely. WRITE_TIMEOUT caps the whole response, summary streams
+    // included, and must outlast REQUEST_TIMEOUT, which already bounds every
+    // handler; otherwise the connection is cut before a slow handler's 503
+    // or the end of a stream can be written.
+    readTimeout, err := getEnvDuration("READ_TIMEOUT", 30*time.Second)
+    if err != nil {
+        fatal(err)
+    }
+    writeTimeout, err := getEnvDuration("WRITE_TIMEOUT", 60*time.Second)
+    if err != nil {
+        fatal(err)
+    }
+    idleTimeout, err := getEnvDuration("IDLE_TIMEOUT", 120*time.Second)
+    if err != nil {
+        fatal(err)
+    }
+    if writeTimeout > 0 && (requestTimeout <= 0 || writeTimeout <= requestTimeout) {
+        slog.Warn("WRITE_TIMEOUT does not outlast REQUEST_TIMEOUT; long responses such as summary streams may be cut off",
+            "write_timeout", writeTimeout, "request_timeout", requestTimeout)
+    }
+    prettyJSON, err := getEnvBool("PRETTY_JSON", false)
+    if err != nil {
+        fatal(err)
+    }
+    pageSize, err := getEnvInt("DEFAULT_PAGE_SIZE", students.DefaultPageSize)
+    if err != nil {
+        fatal(err)
+    }
+    maxPageSize, err := getEnvInt("MAX_PAGE_SIZE", students.DefaultMaxPageSize)
+    if err != nil {
+        fatal(err)
+    }
+    if pageSize < 1 || pageSize > maxPageSize {
+        fatal(errors.New("invalid DEFAULT_PAGE_SIZE or MAX_PAGE_SIZE: need 1 <= DEFAULT_PAGE_SIZE <= MAX_PAGE_SIZE"))
+    }
+    defaultAge, err := getEnvInt("DEFAULT_AGE", 0)
+    if err != nil {
+        fatal(err)
+    }
+    defaults := students.StudentDefaults{Age: defaultAge}
+    if err := defaults.Validate(); err != nil {
+        fatal(fmt.Errorf("invalid DEFAULT_AGE: %v", err))
+    }
+    summaryCacheTTL, err := getEnvDuration("SUMMARY_CACHE_TTL", students.DefaultSummaryCacheTTL)
+    if err != nil {
+        fatal(err)
+    }
+    if summaryCacheTTL < 0 {
+        fatal(errors.New("invalid SUMMARY_CACHE_TTL: must not be negative"))
+    }
+    maxStudents, err := getEnvInt("MAX_STUDENTS", 0)
+    if err != nil {
+        fatal(err)
+    }
+    if maxStudents < 0 {
+        fatal(errors.New("invalid MAX_STUDENTS: must not be negative"))
+    }
+    // Soft-deleted students still take up storage, so they count by default
+    countDeleted, err := getEnvBool("MAX_STUDENTS_COUNT_DELETED", true)
+    if err != nil {
+        fatal(err)
+    }
+    historyLimit, err := getEnvInt("HISTORY_LIMIT", students.DefaultHistoryLimit)
+    if err != nil {
+        fatal(err)
+    }
+    if historyLimit < 0 {
+        fatal(errors.New("invalid HISTORY_LIMIT: must not be negative"))
     }
 
-    var summary bytes.Buffer
-    decoder := json.NewDecoder(resp.Body)
+    idFormat, err := students.ParseIDFormat(getEnv("ID_FORMAT", "numeric"))
+    if err != nil {
+        fatal(fmt.Errorf("invalid ID_FORMAT: %v", err))
+    }
 
-    for decoder.More() {
-        var chunk map[string]interface{}
-        if err := decoder.Decode(&chunk); err != nil {
-            return "", fmt.Errorf("Failed to decode chunk: %v", err)
+    var store students.StudentStore
+    switch backend := getEnv("STORE", "sqlite"); backend {
+    case "sqlite":
+        sqliteStore, err := students.NewSQLiteStore(getEnv("DB_PATH", "students.db"), idFormat)
+        if err != nil {
+            fatal(err)
         }
-
-        // Append the response text
-        if response, ok := chunk["response"].(string); ok {
-            summary.WriteString(response)
+        defer sqliteStore.Close()
+        store = sqliteStore
+    case "memory":
+        store = students.NewInMemoryStore(idFormat)
+    case "sharded":
+        shards, err := getEnvInt("STORE_SHARDS", students.DefaultShards)
+        if err != nil {
+            fatal(err)
         }
-
-        // Check for the "done" flag to stop reading
-        if done, ok := chunk["done"].(bool); ok && done {
-            break
+        if shards < 1 {
+            fatal(errors.New("invalid STORE_SHARDS: must be at least 1"))
         }
+        store = students.NewShardedStore(shards, idFormat)
+    default:
+        fatal(fmt.Errorf("Unknown STORE %q (want sqlite, memory or sharded)", backend))
     }
+    srv := students.NewServer(store, students.NewOllamaClient(ollamaConfig))
+    srv.SetPrettyJSON(prettyJSON)
+    srv.SetPageSizes(pageSize, maxPageSize)
+    srv.SetHistoryLimit(historyLimit)
+    srv.SetDefaults(defaults)
+    srv.SetSummaryCacheTTL(summaryCacheTTL)
+    srv.SetCapacity(maxStudents, countDeleted)
 
-    log.Println("Generated Summary:", summary.String())
-    return summary.String(), nil
-}
+    r := mux.NewRouter()
+    r.Use(metricsMiddleware)
+    r.Handle("/metrics", promhttp.Handler()).Methods("GET")
+    r.HandleFunc("/health", srv.Health).Methods("GET")
+    r.HandleFunc("/ready", srv.Ready).Methods("GET")
+    r.HandleFunc("/openapi.json", srv.OpenAPISpec).Methods("GET")
+    r.HandleFunc("/students", srv.CreateStudent).Methods("POST")
+    r.HandleFunc("/students/bulk", srv.BulkCreateStudents).Methods("POST")
+    r.HandleFunc("/students/import", srv.ImportStudents).Methods("POST")
+    r.HandleFunc("/students/validate", srv.ValidateStudent).Methods("POST")
+    r.HandleFunc("/students", srv.GetStudents).Methods("GET")
+    r.HandleFunc("/students", srv.DeleteAllStudents).Methods("DELETE")
+    r.HandleFunc("/students", srv.BulkPatchStudents).Methods("PATCH")
+    r.HandleFunc("/students/count", srv.CountStudents).Methods("GET")
+    r.HandleFunc("/students/export", srv.ExportStudents).Methods("GET")
+    r.HandleFunc("/students.jsonl", srv.ExportStudentsJSONLines).Methods("GET")
+    r.HandleFunc("/students/search", srv.SearchStudents).Methods("GET")
+    r.HandleFunc("/students/stats/domains", srv.DomainStats).Methods("GET")
+    r.HandleFunc("/students/stats/age", srv.AgeStats).Methods("GET")
+    r.HandleFunc("/students/{id}", srv.GetStudentByID).Methods("GET")
+    r.HandleFunc("/students/{id}", srv.UpdateStudentByID).Methods("PUT")
+    r.HandleFunc("/students/{id}", srv.PatchStudentByID).Methods("PATCH")
+    r.HandleFunc("/students/{id}", srv.DeleteStudentByID).Methods("DELETE")
+    r.HandleFunc("/students/{id}/restore", srv.RestoreStudentByID).Methods("POST")
+    r.HandleFunc("/students/{id}/birthday", srv.CelebrateBirthday).Methods("POST")
+    r.HandleFunc("/students/{id}/activate", srv.ActivateStudent).Methods("POST")
+    r.HandleFunc("/students/{id}/deactivate", srv.DeactivateStudent).Methods("POST")
+    r.HandleFunc("/students/{id}/clone", srv.CloneStudent).Methods("POST")
+    r.HandleFunc("/students/{id}/nearest-age", srv.NearestAgeStudent).Methods("GET")
+    r.HandleFunc("/students/{id}/history", srv.GetStudentHistory).Methods("GET")
+    r.HandleFunc("/students/{id}/email-change", srv.RequestEmailChange).Methods("POST")
+    r.HandleFunc("/students/{id}/email-change/confirm", srv.ConfirmEmailChange).Methods("POST")
+    r.Handle("/students/summaries", summaryLimiter.middleware(http.HandlerFunc(srv.BatchStudentSummaries))).Methods("POST")
+    r.Handle("/students/{id}/summary", summaryLimiter.middleware(http.HandlerFunc(srv.GetStudentSummary))).Methods("GET")
+    r.Handle("/students/{id}/summary/refresh", summaryLimiter.middleware(http.HandlerFunc(srv.RefreshStudentSummary))).Methods("POST")
+    r.Handle("/students/{id}/summary/stream", summaryLimiter.middleware(http.HandlerFunc(srv.GetStudentSummaryStream))).Methods("GET")
+
+    cors := corsMiddleware(getEnvList("CORS_ALLOWED_ORIGINS", []string{"*"}))
+    auth := authMiddleware(getEnvList("API_KEYS", nil), "/health", "/ready", "/openapi.json")
+    // Bulk endpoints opt into a larger body limit than everything else
+    bodyLimit := maxBodyMiddleware(int64(maxBodyBytes), map[string]int64{
+        "/students/bulk":   int64(maxBulkBodyBytes),
+        "/students/import": int64(maxBulkBodyBytes),
+    })
+    // Recovery sits inside the timeout middleware, which runs handlers on their
+    // own goroutine, so a panic is caught, and its stack logged, where it happened
+    handler := requestIDMiddleware(loggingMiddleware(gzipMiddleware(cors(generalLimiter.middleware(auth(bodyLimit(timeoutMiddleware(requestTimeout)(recoveryMiddleware(r)))))))))
+    httpServer := &http.Server{
+        Addr:         fmt.Sprintf(":%d", port),
+        Handler:      handler,
+        ReadTimeout:  readTimeout,
+        WriteTimeout: writeTimeout,
+        IdleTimeout:  idleTimeout,
+    }
 
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
 
+    evictionDone := make(chan struct{})
+    go func() {
+        srv.EvictExpiredSummaries(ctx)
+        close(evictionDone)
+    }()
 
+    go func() {
+        slog.Info("API is running", "port", port)
+        if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+            fatal(err)
+        }
+    }()
 
-func main() {
-    r := mux.NewRouter()
-    r.HandleFunc("/students", CreateStudent).Methods("POST")
-    r.HandleFunc("/students", GetStudents).Methods("GET")
-    r.HandleFunc("/students/{id}", GetStudentByID).Methods("GET")
-    r.HandleFunc("/students/{id}", UpdateStudentByID).Methods("PUT")
-    r.HandleFunc("/students/{id}", DeleteStudentByID).Methods("DELETE")
-    r.HandleFunc("/students/{id}/summary", GetStudentSummary).Methods("GET")
-
-    log.Println("API is running on port 8080...")
-    log.Fatal(http.ListenAndServe(":8080", r))
+    <-ctx.Done()
+    stop() // A second signal kills the process immediately
+    slog.Info("Shutting down, waiting for in-flight requests", "timeout", shutdownTimeout)
+
+    shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+    defer cancel()
+    if err := httpServer.Shutdown(shutdownCtx); err != nil {
+        slog.Warn("Graceful shutdown incomplete", "err", err)
+    }
+    <-evictionDone
+    slog.Info("Server stopped")
 }
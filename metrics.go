@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+    httpRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+        Name: "http_requests_total",
+        Help: "HTTP requests handled, by route template, method and status code.",
+    }, []string{"route", "method", "status"})
+
+    httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+        Name:    "http_request_duration_seconds",
+        Help:    "Time taken to serve HTTP requests, by route template and method.",
+        Buckets: prometheus.DefBuckets,
+    }, []string{"route", "method"})
+)
+
+// metricsMiddleware records request counts and latencies. It is installed
+// on the router with Use so the route template is known, which keeps label
+// cardinality bounded (/students/{id} rather than one series per ID).
+func metricsMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rw := &responseWriter{ResponseWriter: w}
+        next.ServeHTTP(rw, r)
+
+        if rw.status == 0 {
+            rw.status = http.StatusOK
+        }
+        route := "unknown"
+        if current := mux.CurrentRoute(r); current != nil {
+            if tmpl, err := current.GetPathTemplate(); err == nil {
+                route = tmpl
+            }
+        }
+        httpRequestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(rw.status)).Inc()
+        httpRequestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+    })
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+
+	"student_api/internal/students"
+)
+
+// maxRequestIDLength caps client-supplied request IDs echoed into logs
+const maxRequestIDLength = 128
+
+// requestIDMiddleware tags each request with an ID, reusing the client's
+// X-Request-ID header when present, and echoes it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        id := r.Header.Get("X-Request-ID")
+        if id == "" || len(id) > maxRequestIDLength {
+            id = uuid.NewString()
+        }
+        w.Header().Set("X-Request-ID", id)
+        next.ServeHTTP(w, r.WithContext(students.WithRequestID(r.Context(), id)))
+    })
+}
+
+// responseWriter wraps http.ResponseWriter to record the status code and
+// body size of a response
+type responseWriter struct {
+    http.ResponseWriter
+    status int
+    size   int
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+    if rw.status == 0 {
+        rw.status = code
+    }
+    rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(b []byte) (int, error) {
+    if rw.status == 0 {
+        rw.status = http.StatusOK
+    }
+    n, err := rw.ResponseWriter.Write(b)
+    rw.size += n
+    return n, err
+}
+
+// Flush passes through to the wrapped writer so streaming handlers keep working
+func (rw *responseWriter) Flush() {
+    if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+// Unwrap exposes the wrapped writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+    return rw.ResponseWriter
+}
+
+// maxBodyMiddleware caps request bodies at limit bytes, or at the limit
+// listed in overrides for the request's path. A body whose Content-Length
+// already exceeds the cap is refused with 413 up front; otherwise reads past
+// the cap fail with *http.MaxBytesError, which handlers report as 413.
+func maxBodyMiddleware(limit int64, overrides map[string]int64) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            maxBytes := limit
+            if override, ok := overrides[r.URL.Path]; ok {
+                maxBytes = override
+            }
+            if r.ContentLength > maxBytes {
+                students.WriteJSONError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body must not exceed %d bytes", maxBytes))
+                return
+            }
+            r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+            next.ServeHTTP(w, r)
+        })
+    }
+}
+
+// recoveryMiddleware turns a panicking handler into a logged 500 rather than
+// a dropped connection. If the response had already started there is no
+// clean way to report the failure, so the connection is aborted instead.
+func recoveryMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        rw := &responseWriter{ResponseWriter: w}
+        defer func() {
+            p := recover()
+            if p == nil {
+                return
+            }
+            if p == http.ErrAbortHandler {
+                panic(p) // A deliberate abort, which net/http handles quietly
+            }
+            slog.Error("Handler panicked",
+                "request_id", students.RequestIDFromContext(r.Context()),
+                "method", r.Method,
+                "path", r.URL.Path,
+                "panic", p,
+                "stack", string(debug.Stack()))
+            if rw.status != 0 {
+                panic(http.ErrAbortHandler)
+            }
+            students.WriteJSONError(w, http.StatusInternalServerError, "Internal server error")
+        }()
+        next.ServeHTTP(rw, r)
+    })
+}
+
+// loggingMiddleware logs one line per request at info level
+func loggingMiddleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        start := time.Now()
+        rw := &responseWriter{ResponseWriter: w}
+        next.ServeHTTP(rw, r)
+
+        if rw.status == 0 {
+            rw.status = http.StatusOK
+        }
+        slog.Info("request",
+            "request_id", students.RequestIDFromContext(r.Context()),
+            "method", r.Method,
+            "path", r.URL.Path,
+            "status", rw.status,
+            "size", rw.size,
+            "duration", time.Since(start))
+    })
+}
+
+// corsAllowedMethods covers every method the API routes accept
+const corsAllowedMethods = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+
+// corsAllowedHeaders are the request headers browsers may send cross-origin
+const corsAllowedHeaders = "Content-Type, Authorization, X-Request-ID, Idempotency-Key, X-Envelope"
+
+// corsMiddleware adds CORS headers for requests from allowedOrigins and
+// answers preflight requests. A "*" entry allows any origin.
+func corsMiddleware(allowedOrigins []string) func(http.Handler) http.Handler {
+    allowAll := false
+    allowed := make(map[string]bool)
+    for _, origin := range allowedOrigins {
+        if origin == "*" {
+            allowAll = true
+        }
+        allowed[origin] = true
+    }
+
+    return func(next http.Handler) http.Handler {
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            origin := r.Header.Get("Origin")
+            originAllowed := origin != "" && (allowAll || allowed[origin])
+            if originAllowed {
+                if allowAll {
+                    w.Header().Set("Access-Control-Allow-Origin", "*")
+                } else {
+                    w.Header().Set("Access-Control-Allow-Origin", origin)
+                    w.Header().Add("Vary", "Origin")
+                }
+                w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+            }
+
+            // Preflight requests never reach the router
+            if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+                if originAllowed {
+                    w.Header().Set("Access-Control-Allow-Methods", corsAllowedMethods)
+                    w.Header().Set("Access-Control-Allow-Headers", corsAllowedHeaders)
+                    w.Header().Set("Access-Control-Max-Age", "600")
+                }
+                w.WriteHeader(http.StatusNoContent)
+                return
+            }
+            next.ServeHTTP(w, r)
+        })
+    }
+}
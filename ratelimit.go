@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"student_api/internal/students"
+)
+
+// rateLimiterIdleTTL is how long an unused per-IP bucket is kept around
+const rateLimiterIdleTTL = 10 * time.Minute
+
+// ipRateLimiter keeps a token bucket per client IP
+type ipRateLimiter struct {
+    mu        sync.Mutex
+    limit     rate.Limit
+    burst     int
+    clients   map[string]*rateLimitedClient
+    lastPrune time.Time
+}
+
+type rateLimitedClient struct {
+    limiter  *rate.Limiter
+    lastSeen time.Time
+}
+
+// newIPRateLimiter allows each IP limit requests per second with bursts of
+// up to burst requests
+func newIPRateLimiter(limit float64, burst int) *ipRateLimiter {
+    return &ipRateLimiter{
+        limit:     rate.Limit(limit),
+        burst:     burst,
+        clients:   make(map[string]*rateLimitedClient),
+        lastPrune: time.Now(),
+    }
+}
+
+// limiter returns the bucket for ip, creating it on first use
+func (l *ipRateLimiter) limiter(ip string) *rate.Limiter {
+    l.mu.Lock()
+    defer l.mu.Unlock()
+
+    now := time.Now()
+    if now.Sub(l.lastPrune) > rateLimiterIdleTTL {
+        for key, client := range l.clients {
+            if now.Sub(client.lastSeen) > rateLimiterIdleTTL {
+                delete(l.clients, key)
+            }
+        }
+        l.lastPrune = now
+    }
+
+    client, ok := l.clients[ip]
+    if !ok {
+        client = &rateLimitedClient{limiter: rate.NewLimiter(l.limit, l.burst)}
+        l.clients[ip] = client
+    }
+    client.lastSeen = now
+    return client.limiter
+}
+
+// middleware rejects requests over the limit with 429 and a Retry-After
+// header saying when the client's next token will be available
+func (l *ipRateLimiter) middleware(next http.Handler) http.Handler {
+    return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+        reservation := l.limiter(clientIP(r)).Reserve()
+        if delay := reservation.Delay(); delay > 0 {
+            reservation.Cancel()
+            w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(delay.Seconds()))))
+            students.WriteJSONError(w, http.StatusTooManyRequests, "Too many requests")
+            return
+        }
+        next.ServeHTTP(w, r)
+    })
+}
+
+// clientIP returns the IP of the connecting peer. X-Forwarded-For is
+// deliberately ignored since any client can set it to dodge the limit.
+func clientIP(r *http.Request) string {
+    host, _, err := net.SplitHostPort(r.RemoteAddr)
+    if err != nil {
+        return r.RemoteAddr
+    }
+    return host
+}
+
+// loadRateLimiters builds the API-wide limiter and the stricter one guarding
+// the Ollama-backed summary endpoints from the environment
+func loadRateLimiters() (general, summary *ipRateLimiter, err error) {
+    limit, err := getEnvFloat("RATE_LIMIT", 10)
+    if err != nil {
+        return nil, nil, err
+    }
+    burst, err := getEnvInt("RATE_BURST", 20)
+    if err != nil {
+        return nil, nil, err
+    }
+    summaryLimit, err := getEnvFloat("SUMMARY_RATE_LIMIT", 1)
+    if err != nil {
+        return nil, nil, err
+    }
+    summaryBurst, err := getEnvInt("SUMMARY_RATE_BURST", 5)
+    if err != nil {
+        return nil, nil, err
+    }
+    return newIPRateLimiter(limit, burst), newIPRateLimiter(summaryLimit, summaryBurst), nil
+}
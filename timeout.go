@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"student_api/internal/students"
+)
+
+// timeoutMiddleware gives every request a deadline of d, disabled when d is
+// zero. Handlers see it on the request context, so store queries and Ollama
+// calls are cancelled once it passes. A handler that hasn't started its
+// response by then is answered with 503 and its later writes are discarded;
+// one that is already streaming ends when its context does.
+func timeoutMiddleware(d time.Duration) func(http.Handler) http.Handler {
+    return func(next http.Handler) http.Handler {
+        if d <= 0 {
+            return next
+        }
+        return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            ctx, cancel := context.WithTimeout(r.Context(), d)
+            defer cancel()
+
+            tw := &timeoutWriter{w: w, header: make(http.Header)}
+            done := make(chan struct{})
+            panicked := make(chan interface{}, 1)
+            go func() {
+                defer func() {
+                    if p := recover(); p != nil {
+                        panicked <- p
+                    }
+                }()
+                next.ServeHTTP(tw, r.WithContext(ctx))
+                close(done)
+            }()
+
+            select {
+            case p := <-panicked:
+                panic(p) // Re-raised on the serving goroutine so net/http handles it
+            case <-done:
+                // Send the handler's headers even if it wrote no body
+                tw.WriteHeader(http.StatusOK)
+                return
+            case <-ctx.Done():
+            }
+
+            tw.mu.Lock()
+            if !tw.wroteHeader {
+                tw.timedOut = true
+                tw.mu.Unlock()
+                students.WriteJSONError(w, http.StatusServiceUnavailable, "Request timed out")
+                return
+            }
+            tw.mu.Unlock()
+            // The response is under way, so w still belongs to the handler
+            select {
+            case p := <-panicked:
+                panic(p)
+            case <-done:
+            }
+        })
+    }
+}
+
+// timeoutWriter lets timeoutMiddleware take over a response the handler
+// hasn't started. The handler gets its own header map, copied to the real
+// writer when the response starts, so the two never touch it at once.
+type timeoutWriter struct {
+    w      http.ResponseWriter
+    header http.Header
+
+    mu          sync.Mutex
+    wroteHeader bool
+    timedOut    bool
+}
+
+func (tw *timeoutWriter) Header() http.Header {
+    return tw.header
+}
+
+func (tw *timeoutWriter) WriteHeader(code int) {
+    tw.mu.Lock()
+    defer tw.mu.Unlock()
+    if !tw.timedOut && !tw.wroteHeader {
+        tw.writeHeaderLocked(code)
+    }
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+    tw.mu.Lock()
+    defer tw.mu.Unlock()
+    if tw.timedOut {
+        return 0, http.ErrHandlerTimeout
+    }
+    if !tw.wroteHeader {
+        tw.writeHeaderLocked(http.StatusOK)
+    }
+    return tw.w.Write(b)
+}
+
+// Flush passes through to the wrapped writer so streaming handlers keep working
+func (tw *timeoutWriter) Flush() {
+    tw.mu.Lock()
+    defer tw.mu.Unlock()
+    if tw.timedOut {
+        return
+    }
+    if !tw.wroteHeader {
+        tw.writeHeaderLocked(http.StatusOK)
+    }
+    if f, ok := tw.w.(http.Flusher); ok {
+        f.Flush()
+    }
+}
+
+// writeHeaderLocked copies the handler's headers over and starts the
+// response. Callers must hold mu.
+func (tw *timeoutWriter) writeHeaderLocked(code int) {
+    dst := tw.w.Header()
+    for name, values := range tw.header {
+        dst[name] = values
+    }
+    tw.wroteHeader = true
+    tw.w.WriteHeader(code)
+}